@@ -1 +1,2 @@
 terraform import unikraft-cloud_instance.example 550e8400-e29b-41d4-a716-446655440000
+terraform import unikraft-cloud_instance.example dal0/550e8400-e29b-41d4-a716-446655440000
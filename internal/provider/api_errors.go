@@ -0,0 +1,388 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// apiError is an error reported by the Unikraft Cloud API in the payload of
+// a response.
+type apiError struct {
+	StatusCode int
+	Messages   []string
+}
+
+// Error implements error.
+func (e *apiError) Error() string {
+	msg := strings.Join(e.Messages, "; ")
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s (HTTP %d)", msg, e.StatusCode)
+}
+
+// contains returns whether any message of the error contains all the given
+// substrings, case-insensitively.
+func (e *apiError) contains(substrs ...string) bool {
+	for _, m := range e.Messages {
+		m = strings.ToLower(m)
+		found := true
+		for _, s := range substrs {
+			if !strings.Contains(m, s) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// apiCallError is the error returned by a call to the API, annotated with the
+// error described by the API in its response. The latter is retrieved with
+// errors.As.
+type apiCallError struct {
+	err    error
+	apiErr *apiError
+}
+
+// Error implements error.
+func (e *apiCallError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error returned by the call, and the error described by
+// the API.
+func (e *apiCallError) Unwrap() []error {
+	return []error{e.err, e.apiErr}
+}
+
+// withRecordedAPIError annotates err with the error described by the last API
+// response received with ctx, if any. It allows errors to be passed on to
+// callers which did not make the call themselves, such as the callers of a
+// batched request.
+func withRecordedAPIError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	apiErr := recordedAPIError(ctx)
+	if apiErr == nil {
+		return err
+	}
+	return &apiCallError{err: err, apiErr: apiErr}
+}
+
+// apiErrorPayload is the part of the API's response payload which describes
+// errors.
+type apiErrorPayload struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Errors  []struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Data struct {
+		Entries []struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"entries"`
+	} `json:"data"`
+}
+
+// parseAPIError returns the error described by the given API response, or nil
+// if the response does not describe an error.
+func parseAPIError(statusCode int, body []byte) *apiError {
+	var p apiErrorPayload
+	_ = json.Unmarshal(body, &p)
+
+	e := &apiError{StatusCode: statusCode}
+
+	if p.Message != "" && (p.Status == "error" || statusCode >= http.StatusBadRequest) {
+		e.Messages = append(e.Messages, p.Message)
+	}
+	for _, pe := range p.Errors {
+		if pe.Message != "" {
+			e.Messages = append(e.Messages, pe.Message)
+		}
+	}
+	for _, ent := range p.Data.Entries {
+		if ent.Status == "error" && ent.Message != "" {
+			e.Messages = append(e.Messages, ent.Message)
+		}
+	}
+
+	if statusCode < http.StatusBadRequest && len(e.Messages) == 0 {
+		return nil
+	}
+	return e
+}
+
+// apiErrorRecorder holds the status code of, and the error described by, the
+// last API response received within an operation of the provider.
+type apiErrorRecorder struct {
+	mu         sync.Mutex
+	statusCode int
+	err        *apiError
+}
+
+type apiErrorRecorderKey struct{}
+
+// withAPIErrorRecorder returns a copy of ctx in which the errors reported by
+// the API are recorded, for retrieval with recordedAPIError.
+func withAPIErrorRecorder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiErrorRecorderKey{}, &apiErrorRecorder{})
+}
+
+// recordedAPIError returns the error described by the last API response
+// received with the given context, if any.
+func recordedAPIError(ctx context.Context) *apiError {
+	rec, ok := ctx.Value(apiErrorRecorderKey{}).(*apiErrorRecorder)
+	if !ok {
+		return nil
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.err
+}
+
+// recordedStatusCode returns the HTTP status code of the last API response
+// received with the given context, or 0 if there is none.
+func recordedStatusCode(ctx context.Context) int {
+	rec, ok := ctx.Value(apiErrorRecorderKey{}).(*apiErrorRecorder)
+	if !ok {
+		return 0
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.statusCode
+}
+
+// apiErrorTransport is a http.RoundTripper which parses the errors described
+// by API responses and records them in the request's context, so that they
+// can be reported as specific diagnostics.
+type apiErrorTransport struct {
+	next http.RoundTripper
+}
+
+var _ http.RoundTripper = (*apiErrorTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *apiErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	rec, ok := req.Context().Value(apiErrorRecorderKey{}).(*apiErrorRecorder)
+	if err != nil || !ok {
+		return resp, err
+	}
+
+	b, rerr := readResponseBody(resp)
+	if rerr != nil {
+		return resp, rerr
+	}
+
+	rec.mu.Lock()
+	rec.statusCode = resp.StatusCode
+	rec.err = parseAPIError(resp.StatusCode, b)
+	rec.mu.Unlock()
+
+	return resp, nil
+}
+
+// apiFieldRe matches API error messages which refer to a field of the request,
+// e.g. "service_group.services[0].port: must be between 1 and 65535".
+var apiFieldRe = regexp.MustCompile(`^([a-z_]+(?:\[\d+\])*(?:\.[a-z_]+(?:\[\d+\])*)*)\s*:\s*(.+)$`)
+
+// apiFieldSegmentRe matches a single segment of a field reference.
+var apiFieldSegmentRe = regexp.MustCompile(`^([a-z_]+)((?:\[\d+\])*)$`)
+
+// apiFieldPaths maps the top-level fields of API requests to the paths of the
+// corresponding attributes of the instance resource.
+var apiFieldPaths = map[string]path.Path{
+	"name":           path.Root("name"),
+	"image":          path.Root("image"),
+	"args":           path.Root("args"),
+	"env":            path.Root("env"),
+	"memory_mb":      path.Root("memory_mb"),
+	"autostart":      path.Root("autostart"),
+	"restart_policy": path.Root("restart_policy"),
+	"service_group":  path.Root("service_group"),
+	"services":       path.Root("service_group").AtName("services"),
+	"domains":        path.Root("service_group").AtName("domains"),
+}
+
+// attributePath returns the path of the resource attribute designated by the
+// given field reference of an API error message, given the paths of the
+// attributes which correspond to the top-level fields of the request.
+func attributePath(paths map[string]path.Path, field string) (path.Path, bool) {
+	var p path.Path
+
+	for i, seg := range strings.Split(field, ".") {
+		m := apiFieldSegmentRe.FindStringSubmatch(seg)
+		if m == nil {
+			return path.Empty(), false
+		}
+
+		if i == 0 {
+			var ok bool
+			if p, ok = paths[m[1]]; !ok {
+				return path.Empty(), false
+			}
+		} else {
+			p = p.AtName(m[1])
+		}
+
+		for _, idx := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if idx == "" {
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil {
+				return path.Empty(), false
+			}
+			p = p.AtListIndex(n)
+		}
+	}
+
+	return p, true
+}
+
+// apiErrorOptions are the options of apiErrorDiagnostics.
+type apiErrorOptions struct {
+	// paths maps the top-level fields of the API request to the paths of the
+	// corresponding attributes.
+	paths map[string]path.Path
+}
+
+// apiErrorOption is an option of apiErrorDiagnostics.
+type apiErrorOption func(*apiErrorOptions)
+
+// withAttributePaths attaches the diagnostics which concern a field of the API
+// request to the corresponding attribute, as given by paths. Only requests
+// which are built from the attributes of a resource, i.e. which create it,
+// have such fields.
+func withAttributePaths(paths map[string]path.Path) apiErrorOption {
+	return func(o *apiErrorOptions) {
+		o.paths = paths
+	}
+}
+
+// apiErrorDiagnostics returns the diagnostics describing the failure of the
+// given operation. The error described by the API, either carried by err or
+// recorded in ctx, is mapped to a specific diagnostic; err is reported as is
+// otherwise.
+func apiErrorDiagnostics(ctx context.Context, op string, err error, opts ...apiErrorOption) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var o apiErrorOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// addFieldError adds an error concerning the given field of the request,
+	// attached to the corresponding attribute if known.
+	addFieldError := func(field, summary, detail string) {
+		if p, ok := o.paths[field]; ok {
+			diags.AddAttributeError(p, summary, detail)
+			return
+		}
+		diags.AddError(summary, detail)
+	}
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = recordedAPIError(ctx)
+	}
+	if apiErr == nil {
+		diags.AddError(
+			"Client Error",
+			fmt.Sprintf("Failed to %s, got error: %v", op, err),
+		)
+		return diags
+	}
+
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized:
+		diags.AddError(
+			"Invalid Unikraft Cloud API Token",
+			fmt.Sprintf("Failed to %s because the Unikraft Cloud API rejected the API token. "+
+				"Ensure that the token set in the provider configuration, in the UKC_TOKEN environment variable "+
+				"or in the kraftkit configuration file is valid and has not expired.\n\nAPI error: %s", op, apiErr),
+		)
+
+	case apiErr.contains("quota"):
+		diags.AddError(
+			"Unikraft Cloud Quota Exceeded",
+			fmt.Sprintf("Failed to %s because it would exceed a quota of the account. "+
+				"Free resources, e.g. by deleting unused instances, or request a quota increase.\n\nAPI error: %s", op, apiErr),
+		)
+
+	case apiErr.contains("image", "not found"), apiErr.contains("image", "does not exist"):
+		addFieldError(
+			"image",
+			"Image Not Found",
+			fmt.Sprintf("Failed to %s because the image does not exist. "+
+				"Ensure that the image was pushed to the registry, e.g. with \"kraft pkg --push\", "+
+				"and that its name and tag are correct.\n\nAPI error: %s", op, apiErr),
+		)
+
+	case apiErr.StatusCode == http.StatusConflict, apiErr.contains("already exists"), apiErr.contains("already in use"):
+		addFieldError(
+			"name",
+			"Name Already In Use",
+			fmt.Sprintf("Failed to %s because the name is already used by another resource in the metro. "+
+				"Choose a different name, or import the existing resource.\n\nAPI error: %s", op, apiErr),
+		)
+
+	case apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity:
+		for _, msg := range apiErr.Messages {
+			if m := apiFieldRe.FindStringSubmatch(msg); m != nil {
+				if p, ok := attributePath(o.paths, m[1]); ok {
+					diags.AddAttributeError(
+						p,
+						"Invalid Attribute Value",
+						fmt.Sprintf("Failed to %s because the Unikraft Cloud API rejected the value: %s", op, m[2]),
+					)
+					continue
+				}
+			}
+			diags.AddError(
+				"Invalid Request",
+				fmt.Sprintf("Failed to %s because the Unikraft Cloud API rejected the request: %s", op, msg),
+			)
+		}
+		if len(apiErr.Messages) == 0 {
+			diags.AddError(
+				"Invalid Request",
+				fmt.Sprintf("Failed to %s because the Unikraft Cloud API rejected the request, got error: %v", op, err),
+			)
+		}
+
+	default:
+		diags.AddError(
+			"Client Error",
+			fmt.Sprintf("Failed to %s, got error: %v\n\nAPI error: %s", op, err, apiErr),
+		)
+	}
+
+	return diags
+}
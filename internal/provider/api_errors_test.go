@@ -0,0 +1,263 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+func TestParseAPIError(t *testing.T) {
+	testCases := map[string]struct {
+		statusCode int
+		body       string
+		// want is nil when no error is expected.
+		want *apiError
+	}{
+		"success": {
+			statusCode: http.StatusOK,
+			body:       `{"status":"success","data":{"instances":[]}}`,
+		},
+		"error message": {
+			statusCode: http.StatusUnauthorized,
+			body:       `{"status":"error","message":"invalid token"}`,
+			want:       &apiError{StatusCode: http.StatusUnauthorized, Messages: []string{"invalid token"}},
+		},
+		"errors list": {
+			statusCode: http.StatusBadRequest,
+			body:       `{"status":"error","errors":[{"status":400,"message":"name: too long"},{"status":400,"message":""}]}`,
+			want:       &apiError{StatusCode: http.StatusBadRequest, Messages: []string{"name: too long"}},
+		},
+		"erroneous entries of a successful response": {
+			statusCode: http.StatusOK,
+			body:       `{"status":"partial_success","data":{"entries":[{"status":"success"},{"status":"error","message":"instance not found"}]}}`,
+			want:       &apiError{StatusCode: http.StatusOK, Messages: []string{"instance not found"}},
+		},
+		"message of a successful response": {
+			statusCode: http.StatusOK,
+			body:       `{"status":"success","message":"ok"}`,
+		},
+		"undecodable body": {
+			statusCode: http.StatusBadGateway,
+			body:       `<html>Bad Gateway</html>`,
+			want:       &apiError{StatusCode: http.StatusBadGateway},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := parseAPIError(tc.statusCode, []byte(tc.body))
+
+			switch {
+			case tc.want == nil && got != nil:
+				t.Fatalf("expected no error, got %v", got)
+			case tc.want == nil:
+				return
+			case got == nil:
+				t.Fatalf("expected error %v, got none", tc.want)
+			}
+
+			if got.StatusCode != tc.want.StatusCode || !slices.Equal(got.Messages, tc.want.Messages) {
+				t.Errorf("expected %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAttributePath(t *testing.T) {
+	testCases := map[string]struct {
+		field  string
+		want   path.Path
+		wantOK bool
+	}{
+		"top-level field": {
+			field:  "memory_mb",
+			want:   path.Root("memory_mb"),
+			wantOK: true,
+		},
+		"list element": {
+			field:  "args[1]",
+			want:   path.Root("args").AtListIndex(1),
+			wantOK: true,
+		},
+		"nested field": {
+			field:  "service_group.services[0].port",
+			want:   path.Root("service_group").AtName("services").AtListIndex(0).AtName("port"),
+			wantOK: true,
+		},
+		"field relative to the service group": {
+			field:  "domains[2].name",
+			want:   path.Root("service_group").AtName("domains").AtListIndex(2).AtName("name"),
+			wantOK: true,
+		},
+		"unknown field": {
+			field: "volumes[0].size",
+		},
+		"invalid segment": {
+			field: "service_group..services",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := attributePath(apiFieldPaths, tc.field)
+			if ok != tc.wantOK {
+				t.Fatalf("attributePath(%q) ok = %t; expected %t", tc.field, ok, tc.wantOK)
+			}
+			if ok && !got.Equal(tc.want) {
+				t.Errorf("attributePath(%q) = %s; expected %s", tc.field, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorDiagnostics(t *testing.T) {
+	type wantDiag struct {
+		summary string
+		// path is the attribute the diagnostic applies to, if any.
+		path *path.Path
+	}
+
+	atPath := func(p path.Path) *path.Path { return &p }
+
+	testCases := map[string]struct {
+		err error
+		// opts are the options of the call, which default to the ones of the
+		// creation of an instance.
+		opts []apiErrorOption
+		want []wantDiag
+	}{
+		"not an API error": {
+			err:  errors.New("connection refused"),
+			want: []wantDiag{{summary: "Client Error"}},
+		},
+		"invalid token": {
+			err:  &apiError{StatusCode: http.StatusUnauthorized, Messages: []string{"invalid token"}},
+			want: []wantDiag{{summary: "Invalid Unikraft Cloud API Token"}},
+		},
+		"quota exceeded": {
+			err:  &apiError{StatusCode: http.StatusForbidden, Messages: []string{"Memory quota exceeded"}},
+			want: []wantDiag{{summary: "Unikraft Cloud Quota Exceeded"}},
+		},
+		"image not found": {
+			err:  &apiError{StatusCode: http.StatusNotFound, Messages: []string{"Image 'nginx:nope' not found"}},
+			want: []wantDiag{{summary: "Image Not Found", path: atPath(path.Root("image"))}},
+		},
+		"image does not exist": {
+			err:  &apiError{StatusCode: http.StatusBadRequest, Messages: []string{"image does not exist"}},
+			want: []wantDiag{{summary: "Image Not Found", path: atPath(path.Root("image"))}},
+		},
+		"name conflict": {
+			err:  &apiError{StatusCode: http.StatusConflict, Messages: []string{"conflict"}},
+			want: []wantDiag{{summary: "Name Already In Use", path: atPath(path.Root("name"))}},
+		},
+		"name already in use": {
+			err:  &apiError{StatusCode: http.StatusBadRequest, Messages: []string{"name already in use"}},
+			want: []wantDiag{{summary: "Name Already In Use", path: atPath(path.Root("name"))}},
+		},
+		"invalid field": {
+			err: &apiError{StatusCode: http.StatusBadRequest, Messages: []string{
+				"service_group.services[0].port: must be between 1 and 65535",
+			}},
+			want: []wantDiag{{
+				summary: "Invalid Attribute Value",
+				path:    atPath(path.Root("service_group").AtName("services").AtListIndex(0).AtName("port")),
+			}},
+		},
+		"image not found without attribute paths": {
+			err:  &apiError{StatusCode: http.StatusNotFound, Messages: []string{"Image 'nginx:nope' not found"}},
+			opts: []apiErrorOption{},
+			want: []wantDiag{{summary: "Image Not Found"}},
+		},
+		"name conflict without attribute paths": {
+			err:  &apiError{StatusCode: http.StatusConflict, Messages: []string{"conflict"}},
+			opts: []apiErrorOption{},
+			want: []wantDiag{{summary: "Name Already In Use"}},
+		},
+		"invalid field without attribute paths": {
+			err: &apiError{StatusCode: http.StatusBadRequest, Messages: []string{
+				"memory_mb: must be at least 16",
+			}},
+			opts: []apiErrorOption{},
+			want: []wantDiag{{summary: "Invalid Request"}},
+		},
+		"unprocessable fields": {
+			err: &apiError{StatusCode: http.StatusUnprocessableEntity, Messages: []string{
+				"memory_mb: must be at least 16",
+				"volumes[0]: unsupported",
+				"request is invalid",
+			}},
+			want: []wantDiag{
+				{summary: "Invalid Attribute Value", path: atPath(path.Root("memory_mb"))},
+				{summary: "Invalid Request"},
+				{summary: "Invalid Request"},
+			},
+		},
+		"invalid request without message": {
+			err:  &apiError{StatusCode: http.StatusBadRequest},
+			want: []wantDiag{{summary: "Invalid Request"}},
+		},
+		"other API error": {
+			err:  &apiError{StatusCode: http.StatusInternalServerError, Messages: []string{"internal error"}},
+			want: []wantDiag{{summary: "Client Error"}},
+		},
+		"wrapped API error": {
+			err: &apiCallError{
+				err:    errors.New("unexpected response"),
+				apiErr: &apiError{StatusCode: http.StatusUnauthorized},
+			},
+			want: []wantDiag{{summary: "Invalid Unikraft Cloud API Token"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			opts := tc.opts
+			if opts == nil {
+				opts = []apiErrorOption{withAttributePaths(apiFieldPaths)}
+			}
+			diags := apiErrorDiagnostics(context.Background(), "create instance", tc.err, opts...)
+
+			if len(diags) != len(tc.want) {
+				t.Fatalf("expected %d diagnostics, got %d: %v", len(tc.want), len(diags), diags)
+			}
+			for i, want := range tc.want {
+				d := diags[i]
+				if d.Severity() != diag.SeverityError {
+					t.Errorf("diagnostic %d: expected an error, got %s", i, d.Severity())
+				}
+				if d.Summary() != want.summary {
+					t.Errorf("diagnostic %d: expected summary %q, got %q", i, want.summary, d.Summary())
+				}
+
+				dp, ok := d.(diag.DiagnosticWithPath)
+				switch {
+				case want.path == nil && ok:
+					t.Errorf("diagnostic %d: expected no attribute path, got %s", i, dp.Path())
+				case want.path != nil && !ok:
+					t.Errorf("diagnostic %d: expected attribute path %s, got none", i, *want.path)
+				case want.path != nil && !dp.Path().Equal(*want.path):
+					t.Errorf("diagnostic %d: expected attribute path %s, got %s", i, *want.path, dp.Path())
+				}
+			}
+		})
+	}
+
+	t.Run("recorded API error", func(t *testing.T) {
+		ctx := withAPIErrorRecorder(context.Background())
+		rec := ctx.Value(apiErrorRecorderKey{}).(*apiErrorRecorder)
+		rec.err = &apiError{StatusCode: http.StatusConflict}
+
+		diags := apiErrorDiagnostics(ctx, "create instance", errors.New("unexpected response"))
+		if len(diags) != 1 || diags[0].Summary() != "Name Already In Use" {
+			t.Errorf("expected a name conflict diagnostic, got %v", diags)
+		}
+	})
+}
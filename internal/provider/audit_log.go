@@ -0,0 +1,116 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operations recorded in the audit log.
+const (
+	auditOpCreate = "create"
+	auditOpDelete = "delete"
+	auditOpStart  = "start"
+	auditOpStop   = "stop"
+)
+
+// auditLogger appends a record of every API call which modifies Unikraft Cloud
+// resources to a local file, in the JSON Lines format.
+//
+// A nil *auditLogger is valid and discards all records.
+type auditLogger struct {
+	path string
+
+	// mu serializes writes from concurrent resource operations.
+	mu sync.Mutex
+}
+
+// auditRecord is a single entry of the audit log.
+type auditRecord struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Operation    string    `json:"operation"`
+	ResourceType string    `json:"resource_type"`
+	Metro        string    `json:"metro"`
+	UUID         string    `json:"uuid,omitempty"`
+	Request      any       `json:"request,omitempty"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// newAuditLogger returns an auditLogger which writes to the file at path. It
+// ensures that the file can be written to.
+func newAuditLogger(path string) (*auditLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing audit log: %w", err)
+	}
+
+	return &auditLogger{path: path}, nil
+}
+
+// Log appends the given record to the audit log. The outcome of the recorded
+// call is derived from callErr. Sensitive values of the record's request are
+// redacted.
+func (l *auditLogger) Log(rec auditRecord, callErr error) error {
+	if l == nil {
+		return nil
+	}
+
+	rec.Timestamp = time.Now().UTC()
+	rec.Status = "success"
+	if callErr != nil {
+		rec.Status = "error"
+		rec.Error = callErr.Error()
+	}
+	if rec.Request != nil {
+		rec.Request = redactRequest(rec.Request)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding audit record: %w", err)
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The file is re-opened for each record so that it can be rotated
+	// externally while the provider is running.
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+
+	return f.Close()
+}
+
+// redactRequest returns a copy of the given API request, in its JSON
+// representation, with sensitive values redacted.
+func redactRequest(req any) any {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+
+	return redactJSON(v, false)
+}
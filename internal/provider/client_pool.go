@@ -0,0 +1,104 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	unikraftcloud "sdk.kraft.cloud"
+	"sdk.kraft.cloud/instances"
+	"sdk.kraft.cloud/users"
+)
+
+// clientPool lazily builds one Unikraft Cloud API client per metro. All
+// clients share the same set of options (e.g. credentials) and only differ by
+// the metro they are bound to.
+type clientPool struct {
+	defaultMetro string
+	metros       *metroCatalog
+	opts         []unikraftcloud.Option
+
+	mu       sync.Mutex
+	clients  map[string]instances.InstancesService
+	batchers map[string]*getBatcher
+}
+
+// newClientPool returns a clientPool which falls back to defaultMetro whenever
+// no metro is explicitly requested. Metros are validated against the given
+// catalog.
+func newClientPool(defaultMetro string, metros *metroCatalog, opts ...unikraftcloud.Option) *clientPool {
+	return &clientPool{
+		defaultMetro: defaultMetro,
+		metros:       metros,
+		opts:         opts,
+		clients:      make(map[string]instances.InstancesService),
+		batchers:     make(map[string]*getBatcher),
+	}
+}
+
+// Metro returns the metro designated by the given attribute value, or the
+// default metro of the pool if that value is null, unknown or empty.
+func (p *clientPool) Metro(v types.String) string {
+	if v.IsNull() || v.IsUnknown() || v.ValueString() == "" {
+		return p.defaultMetro
+	}
+	return v.ValueString()
+}
+
+// ValidateMetro returns an error if the given metro is not offered by the
+// platform.
+func (p *clientPool) ValidateMetro(metro string) error {
+	return p.metros.Validate(metro)
+}
+
+// Instances returns a client for the instances API of the given metro,
+// creating it on first use.
+func (p *clientPool) Instances(metro string) instances.InstancesService {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.instances(metro)
+}
+
+// InstanceGetter returns a getBatcher which coalesces requests for the state of
+// individual instances of the given metro.
+func (p *clientPool) InstanceGetter(metro string) *getBatcher {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if b, ok := p.batchers[metro]; ok {
+		return b
+	}
+
+	b := &getBatcher{client: p.instances(metro)}
+	p.batchers[metro] = b
+
+	return b
+}
+
+// Users returns a client for the users API of the given metro.
+func (p *clientPool) Users(metro string) users.UsersService {
+	return unikraftcloud.NewClient(p.clientOpts(metro)...).Users()
+}
+
+// instances implements Instances. The caller must hold p.mu.
+func (p *clientPool) instances(metro string) instances.InstancesService {
+	if c, ok := p.clients[metro]; ok {
+		return c
+	}
+
+	c := unikraftcloud.NewClient(p.clientOpts(metro)...).Instances()
+	p.clients[metro] = c
+
+	return c
+}
+
+// clientOpts returns the options of an API client bound to the given metro.
+func (p *clientPool) clientOpts(metro string) []unikraftcloud.Option {
+	opts := make([]unikraftcloud.Option, 0, len(p.opts)+1)
+	opts = append(opts, unikraftcloud.WithDefaultMetro(metro))
+	return append(opts, p.opts...)
+}
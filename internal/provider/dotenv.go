@@ -0,0 +1,168 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dotenvKeyRe matches valid names of variables in dotenv files.
+var dotenvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dotenvError is a syntax error in a dotenv file.
+type dotenvError struct {
+	Line int
+	Msg  string
+}
+
+// Error implements error.
+func (e *dotenvError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// readDotenvFile reads the variables of the dotenv file at the given path.
+func readDotenvFile(name string) (map[string]string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseDotenv(string(b))
+}
+
+// parseDotenv parses variables in the dotenv format:
+//
+//   - each line contains a KEY=VALUE assignment, optionally prefixed with
+//     "export";
+//   - empty lines and lines starting with "#" are ignored;
+//   - unquoted values are trimmed, and end at an inline comment (" #");
+//   - single-quoted values are literal;
+//   - double-quoted values support the escape sequences \n, \r, \t, \", \\
+//     and \$;
+//   - quoted values may span multiple lines.
+//
+// Variables are not expanded. When a variable is assigned several times, the
+// last assignment wins. Errors are of type *dotenvError.
+func parseDotenv(data string) (map[string]string, error) {
+	env := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+
+		line := strings.TrimLeft(lines[i], " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimLeft(rest, " \t")
+		}
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &dotenvError{Line: lineNum, Msg: "expected a KEY=VALUE assignment"}
+		}
+		key = strings.TrimRight(key, " \t")
+		if !dotenvKeyRe.MatchString(key) {
+			return nil, &dotenvError{Line: lineNum, Msg: fmt.Sprintf("invalid variable name %q", key)}
+		}
+		val = strings.TrimLeft(val, " \t")
+
+		if val == "" || (val[0] != '"' && val[0] != '\'') {
+			if j := strings.Index(val, " #"); j >= 0 {
+				val = val[:j]
+			}
+			if j := strings.Index(val, "\t#"); j >= 0 {
+				val = val[:j]
+			}
+			env[key] = strings.TrimSpace(val)
+			continue
+		}
+
+		quote := val[0]
+		val = val[1:]
+
+		var b strings.Builder
+		closed := false
+		for {
+			if end := closingQuote(val, quote); end >= 0 {
+				b.WriteString(val[:end])
+				if rest := strings.TrimSpace(val[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, &dotenvError{Line: i + 1, Msg: fmt.Sprintf("unexpected characters after the closing quote of %s", key)}
+				}
+				closed = true
+				break
+			}
+
+			b.WriteString(val)
+			if i+1 >= len(lines) {
+				break
+			}
+			b.WriteByte('\n')
+			i++
+			val = lines[i]
+		}
+		if !closed {
+			return nil, &dotenvError{Line: lineNum, Msg: fmt.Sprintf("unterminated quoted value of %s", key)}
+		}
+
+		if quote == '"' {
+			env[key] = unescapeDotenv(b.String())
+		} else {
+			env[key] = b.String()
+		}
+	}
+
+	return env, nil
+}
+
+// closingQuote returns the index of the given closing quote in s, or -1 if s
+// does not contain it. Characters escaped with a backslash are skipped inside
+// double quotes.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeDotenv replaces the escape sequences of a double-quoted value.
+// Unknown escape sequences are preserved.
+func unescapeDotenv(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	testCases := map[string]struct {
+		data     string
+		want     map[string]string
+		wantLine int // line of the expected error, if any
+	}{
+		"empty": {
+			data: "",
+			want: map[string]string{},
+		},
+		"comments and blank lines": {
+			data: "# comment\n\n  \n  # indented comment\nA=1\n",
+			want: map[string]string{"A": "1"},
+		},
+		"unquoted values are trimmed": {
+			data: "A =  value  \nB=\n",
+			want: map[string]string{"A": "value", "B": ""},
+		},
+		"export prefix": {
+			data: "export A=1\nexport\tB=2\nexported=3\n",
+			want: map[string]string{"A": "1", "B": "2", "exported": "3"},
+		},
+		"inline comments": {
+			data: "A=1 # comment\nB=2\t# comment\nC=a#b\nD='x' # comment\n",
+			want: map[string]string{"A": "1", "B": "2", "C": "a#b", "D": "x"},
+		},
+		"single quotes are literal": {
+			data: `A='a\nb $HOME # c'`,
+			want: map[string]string{"A": `a\nb $HOME # c`},
+		},
+		"double quote escapes": {
+			data: `A="a\nb\tc\r\"d\" \\ \$e \x"`,
+			want: map[string]string{"A": "a\nb\tc\r\"d\" \\ $e \\x"},
+		},
+		"escaped closing quote": {
+			data: `A="a\"" # comment`,
+			want: map[string]string{"A": `a"`},
+		},
+		"multi-line quoted values": {
+			data: "A=\"line 1\nline 2\"\nB='x\n\ny'\nC=3\n",
+			want: map[string]string{"A": "line 1\nline 2", "B": "x\n\ny", "C": "3"},
+		},
+		"CRLF line endings": {
+			data: "A=1\r\nB=\"x\r\ny\"\r\n",
+			want: map[string]string{"A": "1", "B": "x\ny"},
+		},
+		"last assignment wins": {
+			data: "A=1\nA=2\n",
+			want: map[string]string{"A": "2"},
+		},
+		"variables are not expanded": {
+			data: "A=1\nB=${A}\n",
+			want: map[string]string{"A": "1", "B": "${A}"},
+		},
+		"missing assignment": {
+			data:     "A=1\n\nB\n",
+			wantLine: 3,
+		},
+		"invalid name": {
+			data:     "A=1\n1A=2\n",
+			wantLine: 2,
+		},
+		"empty name": {
+			data:     "=1\n",
+			wantLine: 1,
+		},
+		"characters after closing quote": {
+			data:     "A=1\nB=\"x\" y\n",
+			wantLine: 2,
+		},
+		"characters after multi-line closing quote": {
+			data:     "A=\"x\ny\" z\n",
+			wantLine: 2,
+		},
+		"unterminated quote": {
+			data:     "A=1\nB=\"x\nC=2\n",
+			wantLine: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDotenv(tc.data)
+
+			if tc.wantLine != 0 {
+				var perr *dotenvError
+				if !errors.As(err, &perr) {
+					t.Fatalf("expected a *dotenvError, got %v", err)
+				}
+				if perr.Line != tc.wantLine {
+					t.Errorf("expected an error at line %d, got %v", tc.wantLine, perr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,636 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEmulatorStatePath is the file in which the state of the emulator is
+// persisted by default, relative to Terraform's working directory.
+const defaultEmulatorStatePath = ".unikraft-cloud-emulator.json"
+
+// Instance states reported by the emulator.
+const (
+	emulatorStateRunning = "running"
+	emulatorStateStopped = "stopped"
+)
+
+// emulatorBootTimeUS is the boot time reported by the emulator for running
+// instances.
+const emulatorBootTimeUS = 12500
+
+// emulator is a http.RoundTripper which implements the subset of the Unikraft
+// Cloud API used by the provider, in memory. It allows running full
+// plan/apply cycles without a Unikraft Cloud account. Its state is persisted
+// to a local file after every change, so that it survives between runs.
+//
+// Terraform runs a separate provider process per provider configuration, so
+// the same state file may be used by several emulators at once. Every request
+// is therefore served while holding a lock on the state file, after reloading
+// the state from it.
+type emulator struct {
+	path string
+
+	mu    sync.Mutex
+	state emulatorState
+}
+
+var _ http.RoundTripper = (*emulator)(nil)
+
+// emulatorState is the persisted state of the emulator.
+type emulatorState struct {
+	// Metros holds the instances of each metro.
+	Metros map[string][]*emulatedInstance `json:"metros"`
+	// IPs is the number of private IP addresses allocated so far.
+	IPs int `json:"ips"`
+}
+
+// emulatedInstance is an instance of the emulator, in the representation of
+// the API.
+type emulatedInstance struct {
+	UUID              string                 `json:"uuid"`
+	Name              string                 `json:"name"`
+	CreatedAt         string                 `json:"created_at"`
+	State             string                 `json:"state"`
+	Image             string                 `json:"image"`
+	MemoryMB          int                    `json:"memory_mb"`
+	Args              []string               `json:"args"`
+	Env               map[string]string      `json:"env"`
+	RestartPolicy     string                 `json:"restart_policy"`
+	PrivateIP         string                 `json:"private_ip"`
+	PrivateFQDN       string                 `json:"private_fqdn"`
+	ServiceGroup      *emulatedServiceGroup  `json:"service_group,omitempty"`
+	NetworkInterfaces []emulatedNetInterface `json:"network_interfaces"`
+	BootTimeUS        int                    `json:"boot_time_us"`
+}
+
+type emulatedServiceGroup struct {
+	UUID     string            `json:"uuid"`
+	Name     string            `json:"name"`
+	Services []emulatedService `json:"services"`
+	Domains  []emulatedDomain  `json:"domains"`
+}
+
+type emulatedService struct {
+	Port            int      `json:"port"`
+	DestinationPort int      `json:"destination_port"`
+	Handlers        []string `json:"handlers"`
+}
+
+type emulatedDomain struct {
+	Name string `json:"name,omitempty"`
+	FQDN string `json:"fqdn"`
+}
+
+type emulatedNetInterface struct {
+	UUID      string `json:"uuid"`
+	PrivateIP string `json:"private_ip"`
+	MAC       string `json:"mac"`
+}
+
+// emulatorCreateRequest is the payload of a request for creating an instance.
+type emulatorCreateRequest struct {
+	Name          *string           `json:"name"`
+	Image         string            `json:"image"`
+	Args          []string          `json:"args"`
+	Env           map[string]string `json:"env"`
+	MemoryMB      *int              `json:"memory_mb"`
+	Autostart     *bool             `json:"autostart"`
+	RestartPolicy *string           `json:"restart_policy"`
+	ServiceGroup  *struct {
+		Services []emulatedService `json:"services"`
+		Domains  []emulatedDomain  `json:"domains"`
+	} `json:"service_group"`
+}
+
+// emulatorRef designates an instance by UUID or by name.
+type emulatorRef struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// emulatorStatusError is an error reported by the emulator with the given
+// HTTP status code.
+type emulatorStatusError struct {
+	code int
+	msg  string
+}
+
+// Error implements error.
+func (e *emulatorStatusError) Error() string { return e.msg }
+
+// newEmulator returns an emulator which persists its state to the file at
+// path, restoring the state of a previous run if that file exists.
+func newEmulator(path string) (*emulator, error) {
+	e := &emulator{path: path}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// load replaces the in-memory state of the emulator with the content of its
+// state file, or with an empty state if that file does not exist.
+func (e *emulator) load() error {
+	e.state = emulatorState{}
+
+	b, err := os.ReadFile(e.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("reading emulator state: %w", err)
+	default:
+		if err := json.Unmarshal(b, &e.state); err != nil {
+			return fmt.Errorf("decoding emulator state from %s: %w", e.path, err)
+		}
+	}
+
+	if e.state.Metros == nil {
+		e.state.Metros = make(map[string][]*emulatedInstance)
+	}
+
+	return nil
+}
+
+// lockState acquires an exclusive lock on the state file of the emulator,
+// shared with other provider processes, and returns a function which
+// releases it.
+func (e *emulator) lockState() (func(), error) {
+	f, err := os.OpenFile(e.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("locking emulator state: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("locking emulator state: %w", err)
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (e *emulator) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		_ = req.Body.Close()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	unlock, err := e.lockState()
+	if err != nil {
+		return emulatorResponse(req, err, "", nil), nil
+	}
+	defer unlock()
+
+	// Pick up the changes made by other provider processes since the last
+	// request.
+	if err := e.load(); err != nil {
+		return emulatorResponse(req, err, "", nil), nil
+	}
+
+	key, data, err := e.handle(req.Method, emulatorMetro(req.URL.Hostname()), req.URL.Path, body)
+
+	return emulatorResponse(req, err, key, data), nil
+}
+
+// handle serves the API request with the given method, metro, path and body.
+// It returns the entries of the response's data, and the key under which they
+// are returned.
+func (e *emulator) handle(method, metro, urlPath string, body []byte) (string, any, error) {
+	_, p, ok := strings.Cut(urlPath, "/v1/")
+	if !ok {
+		return "", nil, &emulatorStatusError{http.StatusNotFound, "unknown API path " + urlPath}
+	}
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+
+	switch {
+	case len(segs) == 2 && segs[0] == "users" && segs[1] == "quotas" && method == http.MethodGet:
+		return "quotas", []map[string]any{{"uuid": emulatorUUID(), "used": map[string]int{
+			"instances": len(e.state.Metros[metro]),
+		}}}, nil
+
+	case segs[0] != "instances":
+		return "", nil, &emulatorStatusError{http.StatusNotFound, "unknown API path " + urlPath}
+	}
+
+	// Instances designated in the path take precedence over the body.
+	var refs []emulatorRef
+	action := ""
+	switch len(segs) {
+	case 1:
+	case 2:
+		switch segs[1] {
+		case "list", "start", "stop":
+			action = segs[1]
+		default:
+			refs = []emulatorRef{{UUID: segs[1], Name: segs[1]}}
+		}
+	case 3:
+		refs = []emulatorRef{{UUID: segs[1], Name: segs[1]}}
+		action = segs[2]
+	default:
+		return "", nil, &emulatorStatusError{http.StatusNotFound, "unknown API path " + urlPath}
+	}
+	creating := action == "" && method == http.MethodPost
+	if refs == nil && !creating && len(bytes.TrimSpace(body)) > 0 {
+		var err error
+		if refs, err = parseEmulatorRefs(body); err != nil {
+			return "", nil, &emulatorStatusError{http.StatusBadRequest, err.Error()}
+		}
+	}
+
+	switch {
+	case creating:
+		ins, err := e.create(metro, body)
+		if err != nil {
+			return "", nil, err
+		}
+		return "instances", []any{emulatorEntry(ins)}, nil
+
+	case action == "list" && method == http.MethodGet:
+		entries := make([]emulatorRef, 0, len(e.state.Metros[metro]))
+		for _, ins := range e.state.Metros[metro] {
+			entries = append(entries, emulatorRef{UUID: ins.UUID, Name: ins.Name})
+		}
+		return "instances", entries, nil
+
+	case action == "" && method == http.MethodGet:
+		if refs == nil {
+			entries := make([]any, 0, len(e.state.Metros[metro]))
+			for _, ins := range e.state.Metros[metro] {
+				entries = append(entries, emulatorEntry(ins))
+			}
+			return "instances", entries, nil
+		}
+		entries, err := e.lookup(metro, refs, func(ins *emulatedInstance) any {
+			return emulatorEntry(ins)
+		})
+		return "instances", entries, err
+
+	case action == "" && method == http.MethodDelete:
+		entries, err := e.lookup(metro, refs, func(ins *emulatedInstance) any {
+			e.remove(metro, ins.UUID)
+			return map[string]any{"status": "success", "uuid": ins.UUID, "name": ins.Name, "previous_state": ins.State}
+		})
+		return "instances", entries, e.persist(err)
+
+	case (action == "start" || action == "stop") && (method == http.MethodPut || method == http.MethodPost):
+		entries, err := e.lookup(metro, refs, func(ins *emulatedInstance) any {
+			prev := ins.State
+			if action == "start" {
+				ins.State = emulatorStateRunning
+				ins.BootTimeUS = emulatorBootTimeUS
+			} else {
+				ins.State = emulatorStateStopped
+			}
+			return map[string]any{"status": "success", "uuid": ins.UUID, "name": ins.Name, "state": ins.State, "previous_state": prev}
+		})
+		return "instances", entries, e.persist(err)
+	}
+
+	return "", nil, &emulatorStatusError{http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", method, urlPath)}
+}
+
+// emulatorNameInvalidRe matches sequences of characters which are not valid in
+// instance names.
+var emulatorNameInvalidRe = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// create creates an instance in the given metro from the given request
+// payload.
+func (e *emulator) create(metro string, body []byte) (*emulatedInstance, error) {
+	var in emulatorCreateRequest
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, &emulatorStatusError{http.StatusBadRequest, "invalid request body: " + err.Error()}
+	}
+
+	if in.Image == "" {
+		return nil, &emulatorStatusError{http.StatusBadRequest, "image: must not be empty"}
+	}
+
+	ins := &emulatedInstance{
+		UUID:          emulatorUUID(),
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		State:         emulatorStateStopped,
+		Image:         in.Image,
+		MemoryMB:      128,
+		Args:          in.Args,
+		Env:           in.Env,
+		RestartPolicy: "never",
+	}
+
+	if in.Name != nil && *in.Name != "" {
+		ins.Name = *in.Name
+	} else {
+		ins.Name = emulatorName(in.Image)
+	}
+	if !instanceNameRe.MatchString(ins.Name) || len(ins.Name) > maxInstanceNameLength {
+		return nil, &emulatorStatusError{http.StatusBadRequest,
+			"name: must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character"}
+	}
+	for _, other := range e.state.Metros[metro] {
+		if other.Name == ins.Name {
+			return nil, &emulatorStatusError{http.StatusConflict, fmt.Sprintf("instance with name %q already exists", ins.Name)}
+		}
+	}
+
+	if in.MemoryMB != nil && *in.MemoryMB != 0 {
+		if *in.MemoryMB < 16 {
+			return nil, &emulatorStatusError{http.StatusBadRequest, "memory_mb: must be at least 16"}
+		}
+		ins.MemoryMB = *in.MemoryMB
+	}
+	if in.RestartPolicy != nil && *in.RestartPolicy != "" {
+		ins.RestartPolicy = *in.RestartPolicy
+	}
+	if ins.Env == nil {
+		ins.Env = map[string]string{}
+	}
+	if ins.Args == nil {
+		ins.Args = []string{}
+	}
+
+	e.state.IPs++
+	ins.PrivateIP = fmt.Sprintf("10.0.%d.%d", (e.state.IPs/254)%256, e.state.IPs%254+1)
+	ins.PrivateFQDN = ins.Name + ".internal"
+	ins.NetworkInterfaces = []emulatedNetInterface{{
+		UUID:      emulatorUUID(),
+		PrivateIP: ins.PrivateIP,
+		MAC:       fmt.Sprintf("02:00:00:%02x:%02x:%02x", (e.state.IPs>>16)&0xff, (e.state.IPs>>8)&0xff, e.state.IPs&0xff),
+	}}
+
+	if in.ServiceGroup != nil {
+		sg := &emulatedServiceGroup{
+			UUID:     emulatorUUID(),
+			Name:     ins.Name,
+			Services: in.ServiceGroup.Services,
+		}
+		for i, svc := range sg.Services {
+			if svc.Port < 1 || svc.Port > math.MaxUint16 {
+				return nil, &emulatorStatusError{http.StatusBadRequest,
+					fmt.Sprintf("service_group.services[%d].port: must be between 1 and 65535", i)}
+			}
+			if svc.DestinationPort == 0 {
+				sg.Services[i].DestinationPort = svc.Port
+			}
+		}
+
+		// Publicly exposed instances get a domain under the metro's
+		// domain by default.
+		domains := in.ServiceGroup.Domains
+		if len(domains) == 0 && len(sg.Services) > 0 {
+			domains = []emulatedDomain{{Name: ins.Name}}
+		}
+		for _, d := range domains {
+			fqdn := d.Name
+			if !strings.Contains(fqdn, ".") {
+				fqdn += "." + metro + ".kraft.host"
+			}
+			sg.Domains = append(sg.Domains, emulatedDomain{FQDN: fqdn})
+		}
+
+		ins.ServiceGroup = sg
+	}
+
+	if in.Autostart == nil || *in.Autostart {
+		ins.State = emulatorStateRunning
+		ins.BootTimeUS = emulatorBootTimeUS
+	}
+
+	e.state.Metros[metro] = append(e.state.Metros[metro], ins)
+
+	if err := e.persist(nil); err != nil {
+		return nil, err
+	}
+
+	return ins, nil
+}
+
+// lookup applies fn to each of the instances designated by refs in the given
+// metro, and returns the results as response entries. Instances which do not
+// exist are reported as erroneous entries.
+func (e *emulator) lookup(metro string, refs []emulatorRef, fn func(*emulatedInstance) any) ([]any, error) {
+	if len(refs) == 0 {
+		return nil, &emulatorStatusError{http.StatusBadRequest, "no instance specified"}
+	}
+
+	entries := make([]any, 0, len(refs))
+	found := 0
+
+	for _, ref := range refs {
+		ins := e.find(metro, ref)
+		if ins == nil {
+			entries = append(entries, map[string]any{
+				"status":  "error",
+				"uuid":    ref.UUID,
+				"message": fmt.Sprintf("instance %s not found", refName(ref)),
+			})
+			continue
+		}
+		entries = append(entries, fn(ins))
+		found++
+	}
+
+	if found == 0 {
+		return entries, &emulatorStatusError{http.StatusNotFound, fmt.Sprintf("instance %s not found", refName(refs[0]))}
+	}
+
+	return entries, nil
+}
+
+// find returns the instance designated by ref in the given metro, if any.
+func (e *emulator) find(metro string, ref emulatorRef) *emulatedInstance {
+	for _, ins := range e.state.Metros[metro] {
+		if (ref.UUID != "" && ins.UUID == ref.UUID) || (ref.Name != "" && ins.Name == ref.Name) {
+			return ins
+		}
+	}
+	return nil
+}
+
+// remove removes the instance with the given UUID from the given metro.
+func (e *emulator) remove(metro, uuid string) {
+	list := e.state.Metros[metro]
+	for i, ins := range list {
+		if ins.UUID == uuid {
+			e.state.Metros[metro] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// persist writes the state of the emulator to its file, unless err is not nil.
+// The file is replaced atomically, so that it is never left half-written.
+func (e *emulator) persist(err error) error {
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(&e.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding emulator state: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(e.path), filepath.Base(e.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing emulator state: %w", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing emulator state: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing emulator state: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), e.path); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing emulator state: %w", err)
+	}
+
+	return nil
+}
+
+// parseEmulatorRefs decodes the instances designated by the body of a request,
+// either as a single object or as a list of objects.
+func parseEmulatorRefs(body []byte) ([]emulatorRef, error) {
+	var refs []emulatorRef
+	if err := json.Unmarshal(body, &refs); err == nil {
+		return refs, nil
+	}
+
+	var ref emulatorRef
+	if err := json.Unmarshal(body, &ref); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	return []emulatorRef{ref}, nil
+}
+
+// emulatorEntry returns the representation of the given instance as an entry
+// of an API response.
+func emulatorEntry(ins *emulatedInstance) any {
+	return struct {
+		Status string `json:"status"`
+		*emulatedInstance
+	}{"success", ins}
+}
+
+// emulatorResponse builds the HTTP response to the given request, from either
+// an error or the entries of the response's data.
+func emulatorResponse(req *http.Request, err error, key string, data any) *http.Response {
+	code := http.StatusOK
+	payload := map[string]any{
+		"status": "success",
+	}
+
+	if err != nil {
+		code = http.StatusInternalServerError
+		var serr *emulatorStatusError
+		if errors.As(err, &serr) {
+			code = serr.code
+		}
+		payload["status"] = "error"
+		payload["message"] = err.Error()
+		payload["errors"] = []map[string]any{{"status": code, "message": err.Error()}}
+	}
+	if key != "" {
+		payload["data"] = map[string]any{key: data}
+	}
+
+	b, merr := json.Marshal(payload)
+	if merr != nil {
+		code = http.StatusInternalServerError
+		b = []byte(`{"status":"error","message":"encoding response"}`)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}
+}
+
+// emulatorMetro returns the metro targeted by a request to the given host,
+// e.g. "fra0" for "api.fra0.kraft.cloud".
+func emulatorMetro(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) > 2 && parts[0] == "api" {
+		return parts[1]
+	}
+	return host
+}
+
+// emulatorName returns a name for an instance of the given image, e.g.
+// "myapp-1a2b3c4d" for "myuser.unikraft.io/myapp:latest".
+func emulatorName(image string) string {
+	base := image
+	if i := strings.LastIndex(base, "/"); i >= 0 {
+		base = base[i+1:]
+	}
+	if i := strings.IndexAny(base, ":@"); i >= 0 {
+		base = base[:i]
+	}
+
+	base = strings.Trim(emulatorNameInvalidRe.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if base == "" {
+		base = "instance"
+	}
+	if len(base) > 54 {
+		base = base[:54]
+	}
+
+	return base + "-" + emulatorUUID()[:8]
+}
+
+// emulatorUUID returns a random version 4 UUID.
+func emulatorUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// refName returns a human-readable designation of the instance of ref.
+func refName(ref emulatorRef) string {
+	if ref.UUID != "" {
+		return ref.UUID
+	}
+	return fmt.Sprintf("%q", ref.Name)
+}
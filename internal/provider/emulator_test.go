@@ -0,0 +1,289 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	emulatorTestURL  = "https://api.fra0.kraft.cloud/v1/instances"
+	emulatorTestUUID = "5d2b0e3a-7c1f-4b8e-9a6d-2f4c8e1b7a90"
+)
+
+// emulatorTestResponse is the decoded payload of a response of the emulator.
+type emulatorTestResponse struct {
+	Status  string                      `json:"status"`
+	Message string                      `json:"message"`
+	Data    map[string][]map[string]any `json:"data"`
+}
+
+// newTestEmulator returns an emulator backed by a state file in a temporary
+// directory, holding a running instance named "existing" in metro fra0.
+func newTestEmulator(t *testing.T) *emulator {
+	t.Helper()
+
+	e, err := newEmulator(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.state.Metros["fra0"] = []*emulatedInstance{{
+		UUID:  emulatorTestUUID,
+		Name:  "existing",
+		State: emulatorStateRunning,
+		Image: "nginx:latest",
+	}}
+	if err := e.persist(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+// doEmulator sends a request to the emulator and decodes its response.
+func doEmulator(t *testing.T, e *emulator, method, url, body string) (int, emulatorTestResponse) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := e.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var payload emulatorTestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	return resp.StatusCode, payload
+}
+
+func TestEmulatorRoundTrip(t *testing.T) {
+	testCases := map[string]struct {
+		method string
+		path   string
+		body   string
+
+		wantCode int
+		// wantEntries holds, for each expected entry of the response, the
+		// values of some of its fields.
+		wantEntries []map[string]any
+	}{
+		"create": {
+			method:   http.MethodPost,
+			body:     `{"image":"nginx:latest","name":"web","memory_mb":256}`,
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "name": "web", "state": emulatorStateRunning, "memory_mb": float64(256)},
+			},
+		},
+		"create without autostart": {
+			method:   http.MethodPost,
+			body:     `{"image":"nginx:latest","name":"web","autostart":false}`,
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "name": "web", "state": emulatorStateStopped},
+			},
+		},
+		"create with name conflict": {
+			method:   http.MethodPost,
+			body:     `{"image":"nginx:latest","name":"existing"}`,
+			wantCode: http.StatusConflict,
+		},
+		"create without image": {
+			method:   http.MethodPost,
+			body:     `{"name":"web"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"create with invalid name": {
+			method:   http.MethodPost,
+			body:     `{"image":"nginx:latest","name":"Web_1"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"create with invalid port": {
+			method:   http.MethodPost,
+			body:     `{"image":"nginx:latest","service_group":{"services":[{"port":70000}]}}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"get by name": {
+			method:   http.MethodGet,
+			path:     "/existing",
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "uuid": emulatorTestUUID, "name": "existing"},
+			},
+		},
+		"get batch with missing instance": {
+			method:   http.MethodGet,
+			body:     `[{"uuid":"` + emulatorTestUUID + `"},{"uuid":"missing"}]`,
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "uuid": emulatorTestUUID},
+				{"status": "error", "uuid": "missing"},
+			},
+		},
+		"get missing instance": {
+			method:   http.MethodGet,
+			body:     `{"uuid":"missing"}`,
+			wantCode: http.StatusNotFound,
+			wantEntries: []map[string]any{
+				{"status": "error", "uuid": "missing"},
+			},
+		},
+		"list": {
+			method:   http.MethodGet,
+			path:     "/list",
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"uuid": emulatorTestUUID, "name": "existing"},
+			},
+		},
+		"stop": {
+			method:   http.MethodPut,
+			path:     "/stop",
+			body:     `{"name":"existing"}`,
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "state": emulatorStateStopped, "previous_state": emulatorStateRunning},
+			},
+		},
+		"start": {
+			method:   http.MethodPut,
+			path:     "/" + emulatorTestUUID + "/start",
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "state": emulatorStateRunning, "previous_state": emulatorStateRunning},
+			},
+		},
+		"delete": {
+			method:   http.MethodDelete,
+			path:     "/" + emulatorTestUUID,
+			wantCode: http.StatusOK,
+			wantEntries: []map[string]any{
+				{"status": "success", "uuid": emulatorTestUUID, "previous_state": emulatorStateRunning},
+			},
+		},
+		"delete missing instance": {
+			method:   http.MethodDelete,
+			path:     "/missing",
+			wantCode: http.StatusNotFound,
+		},
+		"unknown path": {
+			method:   http.MethodGet,
+			path:     "/" + emulatorTestUUID + "/logs/all",
+			wantCode: http.StatusNotFound,
+		},
+		"method not allowed": {
+			method:   http.MethodPatch,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			e := newTestEmulator(t)
+
+			code, payload := doEmulator(t, e, tc.method, emulatorTestURL+tc.path, tc.body)
+			if code != tc.wantCode {
+				t.Fatalf("expected status code %d, got %d (%s)", tc.wantCode, code, payload.Message)
+			}
+			if code != http.StatusOK && payload.Status != "error" {
+				t.Errorf("expected status %q, got %q", "error", payload.Status)
+			}
+
+			if tc.wantEntries == nil {
+				return
+			}
+			entries := payload.Data["instances"]
+			if len(entries) != len(tc.wantEntries) {
+				t.Fatalf("expected %d entries, got %d: %v", len(tc.wantEntries), len(entries), entries)
+			}
+			for i, want := range tc.wantEntries {
+				for k, v := range want {
+					if entries[i][k] != v {
+						t.Errorf("entry %d: expected %s %v, got %v", i, k, v, entries[i][k])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEmulatorPersistence(t *testing.T) {
+	e := newTestEmulator(t)
+
+	code, _ := doEmulator(t, e, http.MethodPost, emulatorTestURL, `{"image":"nginx:latest","name":"web"}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+	}
+
+	reloaded, err := newEmulator(e.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, payload := doEmulator(t, reloaded, http.MethodGet, emulatorTestURL+"/list", "")
+	if code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+	}
+	if got := len(payload.Data["instances"]); got != 2 {
+		t.Errorf("expected 2 instances after reload, got %d", got)
+	}
+}
+
+func TestEmulatorSharedState(t *testing.T) {
+	// Emulators of distinct provider processes which use the same state file
+	// must not overwrite each other's instances.
+	a := newTestEmulator(t)
+	b, err := newEmulator(a.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		e    *emulator
+		name string
+	}{{a, "web-a"}, {b, "web-b"}} {
+		code, payload := doEmulator(t, tc.e, http.MethodPost, emulatorTestURL, `{"image":"nginx:latest","name":"`+tc.name+`"}`)
+		if code != http.StatusOK {
+			t.Fatalf("creating %s: expected status code %d, got %d (%s)", tc.name, http.StatusOK, code, payload.Message)
+		}
+	}
+
+	code, _ := doEmulator(t, a, http.MethodPost, emulatorTestURL, `{"image":"nginx:latest","name":"web-b"}`)
+	if code != http.StatusConflict {
+		t.Errorf("expected status code %d for a name created by another emulator, got %d", http.StatusConflict, code)
+	}
+
+	_, payload := doEmulator(t, a, http.MethodGet, emulatorTestURL+"/list", "")
+	if got := len(payload.Data["instances"]); got != 3 {
+		t.Errorf("expected 3 instances, got %d", got)
+	}
+}
+
+func TestEmulatorMetro(t *testing.T) {
+	testCases := map[string]string{
+		"api.fra0.kraft.cloud": "fra0",
+		"api.was1.kraft.cloud": "was1",
+		"localhost":            "localhost",
+	}
+
+	for host, want := range testCases {
+		t.Run(host, func(t *testing.T) {
+			if got := emulatorMetro(host); got != want {
+				t.Errorf("emulatorMetro(%q) = %q; expected %q", host, got, want)
+			}
+		})
+	}
+}
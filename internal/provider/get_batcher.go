@@ -0,0 +1,207 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/trace"
+
+	"sdk.kraft.cloud/instances"
+)
+
+const (
+	// getBatchWindow is the time during which requests for the state of
+	// individual instances are collected before being sent as a single batch.
+	getBatchWindow = 20 * time.Millisecond
+	// maxGetBatchSize is the maximum number of instances requested in a single
+	// batch.
+	maxGetBatchSize = 100
+	// getBatchTimeout bounds the duration of a batch, including the requests
+	// for individual instances which failed as part of the batch.
+	getBatchTimeout = 5 * time.Minute
+)
+
+// getBatcher coalesces concurrent requests for the state of individual
+// instances into batched Get calls. This considerably reduces the number of
+// round trips to the API during the refresh of large states, where Terraform
+// reads many instances concurrently.
+type getBatcher struct {
+	client instances.InstancesService
+
+	mu sync.Mutex
+	// pending holds the callers waiting for the next batch, indexed by the
+	// UUID of the instance they requested.
+	pending map[string][]chan<- getResult
+	// values is the context of the first caller of the next batch, which
+	// values, such as the loggers of the provider, are used by the batch.
+	values context.Context
+	// links are the links to the spans of the callers of the next batch.
+	links []trace.Link
+	timer *time.Timer
+}
+
+// getResult is the result of a Get call for an individual instance.
+type getResult struct {
+	ins *instances.GetResponseItem
+	err error
+}
+
+// Get returns the state of the instance with the given UUID. The request is
+// sent as part of a batch together with the requests of concurrent callers.
+func (b *getBatcher) Get(ctx context.Context, uuid string) (*instances.GetResponseItem, error) {
+	ch := make(chan getResult, 1)
+
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string][]chan<- getResult)
+		b.values = ctx
+		b.timer = time.AfterFunc(getBatchWindow, b.flush)
+	}
+	b.pending[uuid] = append(b.pending[uuid], ch)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		b.links = append(b.links, trace.Link{SpanContext: sc})
+	}
+	full := len(b.pending) >= maxGetBatchSize
+	b.mu.Unlock()
+
+	if full {
+		b.flush()
+	}
+
+	select {
+	case res := <-ch:
+		return res.ins, res.err
+	case <-ctx.Done():
+		b.withdraw(uuid, ch)
+		return nil, ctx.Err()
+	}
+}
+
+// withdraw removes the given caller from the next batch, if it was not sent
+// yet, so that instances which no caller waits for any longer are not
+// requested.
+func (b *getBatcher) withdraw(uuid string, ch chan<- getResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chs := slices.DeleteFunc(b.pending[uuid], func(c chan<- getResult) bool { return c == ch })
+	if len(chs) == 0 {
+		delete(b.pending, uuid)
+	} else {
+		b.pending[uuid] = chs
+	}
+}
+
+// flush sends the pending batch, if any.
+func (b *getBatcher) flush() {
+	b.mu.Lock()
+	pending, values, links := b.pending, b.values, b.links
+	b.pending, b.values, b.links = nil, nil, nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	go b.send(values, links, pending)
+}
+
+// send requests the state of all instances of the given batch and delivers
+// the results to their respective callers.
+func (b *getBatcher) send(values context.Context, links []trace.Link, batch map[string][]chan<- getResult) {
+	uuids := make([]string, 0, len(batch))
+	for uuid := range batch {
+		uuids = append(uuids, uuid)
+	}
+
+	// A batch is shared by all its callers, and must not be aborted if any of
+	// them gives up, hence it is not bound to the cancellation, deadline or
+	// span of any caller, but to its own deadline, and its span is linked to
+	// the spans of its callers. Only the values of the first caller, such as
+	// the loggers of the provider, are kept.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(values), getBatchTimeout)
+	defer cancel()
+
+	tracer := trace.SpanFromContext(values).TracerProvider().Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "instances.GetBatch",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(spanAttrInstanceUUID.StringSlice(uuids)),
+	)
+	defer span.End()
+
+	deliver := func(uuid string, res getResult) {
+		for _, ch := range batch[uuid] {
+			ch <- res
+		}
+		delete(batch, uuid)
+	}
+
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, strings.Join(uuids, ","))
+
+	tflog.Debug(ctx, "Getting state of instances in batch", map[string]any{
+		"batch_size": len(uuids),
+	})
+
+	// Errors described by the API are recorded separately for each call, and
+	// carried by the returned errors, since the context is shared by all the
+	// callers of the batch.
+	callCtx := withAPIErrorRecorder(ctx)
+	insRaw, err := b.client.Get(callCtx, uuids...)
+	if err != nil && len(uuids) == 1 {
+		deliver(uuids[0], getResult{err: withRecordedAPIError(callCtx, err)})
+		return
+	}
+	if err == nil {
+		for i := range insRaw.Data.Entries {
+			ins := &insRaw.Data.Entries[i]
+			if _, ok := batch[ins.UUID]; ok && ins.Status != "error" {
+				deliver(ins.UUID, getResult{ins: ins})
+			}
+		}
+	}
+
+	// Instances missing from the batched response or reported as errors, or
+	// all instances of a failed batch (e.g. because a single instance of the
+	// batch no longer exists), are requested individually so that a single
+	// error does not affect unrelated callers.
+	for uuid := range batch {
+		deliver(uuid, b.getOne(tflog.SetField(ctx, logFieldInstanceUUID, uuid), uuid))
+	}
+}
+
+// getOne requests the state of a single instance.
+func (b *getBatcher) getOne(ctx context.Context, uuid string) getResult {
+	ctx = withAPIErrorRecorder(ctx)
+
+	insRaw, err := b.client.Get(ctx, uuid)
+	if err != nil {
+		return getResult{err: withRecordedAPIError(ctx, err)}
+	}
+	if len(insRaw.Data.Entries) == 0 {
+		return getResult{err: fmt.Errorf("no instance with UUID %s in API response", uuid)}
+	}
+
+	// The API may report an error for the instance, e.g. when it no longer
+	// exists, in an entry of an otherwise successful response.
+	ins := &insRaw.Data.Entries[0]
+	if ins.Status == "error" {
+		if apiErr := recordedAPIError(ctx); apiErr != nil {
+			return getResult{err: apiErr}
+		}
+		return getResult{err: fmt.Errorf("failed to get instance %s: %s", uuid, ins.Message)}
+	}
+	return getResult{ins: ins}
+}
@@ -0,0 +1,321 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"sdk.kraft.cloud/client"
+	"sdk.kraft.cloud/instances"
+)
+
+// fakeInstances is an instances.InstancesService which serves Get calls from
+// a fixed set of instances, and records those calls.
+type fakeInstances struct {
+	instances.InstancesService
+
+	// existing holds the UUIDs of the instances which exist.
+	existing map[string]bool
+	// failBatches causes Get calls for more than one instance to fail.
+	failBatches bool
+	// delay is the duration of each Get call.
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls [][]string
+	ctxs  []context.Context
+}
+
+// Get implements instances.InstancesService.
+func (f *fakeInstances) Get(ctx context.Context, ids ...string) (*client.ServiceResponse[instances.GetResponseItem], error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, slices.Clone(ids))
+	f.ctxs = append(f.ctxs, ctx)
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	if f.failBatches && len(ids) > 1 {
+		return nil, errors.New("batch failed")
+	}
+
+	resp := &client.ServiceResponse[instances.GetResponseItem]{}
+	for _, id := range ids {
+		item := instances.GetResponseItem{UUID: id, Name: "name-" + id, Status: "success"}
+		if !f.existing[id] {
+			item = instances.GetResponseItem{UUID: id, Status: "error", Message: "instance not found"}
+		}
+		resp.Data.Entries = append(resp.Data.Entries, item)
+	}
+	return resp, nil
+}
+
+// Calls returns the identifiers requested by each Get call.
+func (f *fakeInstances) Calls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.calls)
+}
+
+// Contexts returns the contexts of each Get call.
+func (f *fakeInstances) Contexts() []context.Context {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.ctxs)
+}
+
+// getConcurrently gets the given instances concurrently, and returns the
+// results in the same order.
+func getConcurrently(ctx context.Context, b *getBatcher, uuids []string) []getResult {
+	results := make([]getResult, len(uuids))
+
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ins, err := b.Get(ctx, uuid)
+			results[i] = getResult{ins: ins, err: err}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func TestGetBatcher(t *testing.T) {
+	testCases := map[string]struct {
+		existing    []string
+		failBatches bool
+		get         []string
+		// wantErr holds the UUIDs which are expected to fail.
+		wantErr []string
+		// wantBatch is the expected number of UUIDs of the first call.
+		wantBatch int
+	}{
+		"single": {
+			existing:  []string{"a"},
+			get:       []string{"a"},
+			wantBatch: 1,
+		},
+		"timer flush": {
+			existing:  []string{"a", "b", "c"},
+			get:       []string{"a", "b", "c"},
+			wantBatch: 3,
+		},
+		"duplicate callers": {
+			existing:  []string{"a", "b"},
+			get:       []string{"a", "a", "b"},
+			wantBatch: 2,
+		},
+		"partial failure": {
+			existing:  []string{"a", "c"},
+			get:       []string{"a", "b", "c"},
+			wantErr:   []string{"b"},
+			wantBatch: 3,
+		},
+		"failed batch": {
+			existing:    []string{"a", "b"},
+			failBatches: true,
+			get:         []string{"a", "b"},
+			wantBatch:   2,
+		},
+		"missing instance": {
+			get:       []string{"a"},
+			wantErr:   []string{"a"},
+			wantBatch: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeInstances{existing: make(map[string]bool), failBatches: tc.failBatches}
+			for _, id := range tc.existing {
+				fake.existing[id] = true
+			}
+			b := &getBatcher{client: fake}
+
+			results := getConcurrently(context.Background(), b, tc.get)
+
+			for i, uuid := range tc.get {
+				res := results[i]
+				if slices.Contains(tc.wantErr, uuid) {
+					if res.err == nil {
+						t.Errorf("Get(%q): expected an error, got instance %+v", uuid, res.ins)
+					}
+					continue
+				}
+				if res.err != nil {
+					t.Errorf("Get(%q): unexpected error: %v", uuid, res.err)
+					continue
+				}
+				if res.ins.UUID != uuid || res.ins.Name != "name-"+uuid {
+					t.Errorf("Get(%q): got instance %+v", uuid, res.ins)
+				}
+			}
+
+			calls := fake.Calls()
+			if len(calls) == 0 || len(calls[0]) != tc.wantBatch {
+				t.Errorf("expected a first call for %d instances, got calls %v", tc.wantBatch, calls)
+			}
+		})
+	}
+}
+
+func TestGetBatcherSizeFlush(t *testing.T) {
+	const n = maxGetBatchSize + maxGetBatchSize/2
+
+	fake := &fakeInstances{existing: make(map[string]bool)}
+	uuids := make([]string, n)
+	for i := range uuids {
+		uuids[i] = fmt.Sprintf("ins-%d", i)
+		fake.existing[uuids[i]] = true
+	}
+	b := &getBatcher{client: fake}
+
+	for i, res := range getConcurrently(context.Background(), b, uuids) {
+		if res.err != nil {
+			t.Errorf("Get(%q): unexpected error: %v", uuids[i], res.err)
+		}
+	}
+
+	var total int
+	for _, call := range fake.Calls() {
+		if len(call) > maxGetBatchSize {
+			t.Errorf("expected batches of at most %d instances, got %d", maxGetBatchSize, len(call))
+		}
+		total += len(call)
+	}
+	if total != n {
+		t.Errorf("expected %d instances to be requested, got %d", n, total)
+	}
+}
+
+func TestGetBatcherCallerCancellation(t *testing.T) {
+	fake := &fakeInstances{
+		existing: map[string]bool{"a": true, "b": true},
+		delay:    50 * time.Millisecond,
+	}
+	b := &getBatcher{client: fake}
+
+	// The first caller of the batch gives up while the batch is in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := b.Get(ctx, "a")
+		errCh <- err
+	}()
+	time.AfterFunc(getBatchWindow+10*time.Millisecond, cancel)
+
+	ins, err := b.Get(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Get(\"b\"): unexpected error: %v", err)
+	}
+	if ins.UUID != "b" {
+		t.Errorf("Get(\"b\"): got instance %+v", ins)
+	}
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get(\"a\"): expected a cancellation error, got %v", err)
+	}
+}
+
+func TestGetBatcherWithdrawal(t *testing.T) {
+	fake := &fakeInstances{existing: map[string]bool{"a": true, "b": true}}
+	b := &getBatcher{client: fake}
+
+	// The caller of a gives up before the batch is sent.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Get(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get(\"a\"): expected a cancellation error, got %v", err)
+	}
+
+	if _, err := b.Get(context.Background(), "b"); err != nil {
+		t.Fatalf("Get(\"b\"): unexpected error: %v", err)
+	}
+
+	if calls := fake.Calls(); len(calls) != 1 || !slices.Equal(calls[0], []string{"b"}) {
+		t.Errorf("expected a single call for b, got calls %v", calls)
+	}
+}
+
+func TestGetBatcherContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(tracerName)
+
+	fake := &fakeInstances{existing: map[string]bool{"a": true, "b": true}}
+	b := &getBatcher{client: fake}
+
+	// The callers have their own traces, and a deadline which is shorter
+	// than the one of the batch.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctxA, spanA := tracer.Start(ctx, "a")
+	ctxB, spanB := tracer.Start(context.Background(), "b")
+
+	var wg sync.WaitGroup
+	for uuid, ctx := range map[string]context.Context{"a": ctxA, "b": ctxB} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Get(ctx, uuid); err != nil {
+				t.Errorf("Get(%q): unexpected error: %v", uuid, err)
+			}
+		}()
+	}
+	wg.Wait()
+	spanA.End()
+	spanB.End()
+
+	ctxs := fake.Contexts()
+	if len(ctxs) != 1 {
+		t.Fatalf("expected a single call, got %d", len(ctxs))
+	}
+	deadline, ok := ctxs[0].Deadline()
+	if !ok || time.Until(deadline) <= time.Minute {
+		t.Errorf("expected the batch to have its own deadline, got %v", deadline)
+	}
+
+	// The batch span ends once the results are delivered.
+	var batch sdktrace.ReadOnlySpan
+	for start := time.Now(); batch == nil && time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		for _, s := range recorder.Ended() {
+			if s.Name() == "instances.GetBatch" {
+				batch = s
+			}
+		}
+	}
+	if batch == nil {
+		t.Fatal("expected a span for the batch")
+	}
+	if batch.Parent().IsValid() {
+		t.Errorf("expected the batch span to be a root span, got parent %v", batch.Parent().SpanID())
+	}
+	if got := trace.SpanContextFromContext(ctxs[0]).SpanID(); got != batch.SpanContext().SpanID() {
+		t.Errorf("expected the call to be part of the batch span, got span %v", got)
+	}
+
+	var linked []trace.SpanID
+	for _, l := range batch.Links() {
+		linked = append(linked, l.SpanContext.SpanID())
+	}
+	for _, span := range []trace.Span{spanA, spanB} {
+		if id := span.SpanContext().SpanID(); !slices.Contains(linked, id) {
+			t.Errorf("expected the batch span to be linked to caller span %v, got links %v", id, linked)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// httpClientConfig describes the settings of the HTTP client used by the
+// provider to communicate with the Unikraft Cloud API.
+type httpClientConfig struct {
+	// Endpoint, when set, is the base URL of the API which replaces the
+	// metro-derived URL of every request.
+	Endpoint string
+	// CACertPEM is a PEM-encoded CA bundle trusted in addition to the
+	// system's certificate pool.
+	CACertPEM []byte
+	// InsecureSkipVerify disables the verification of the API's TLS
+	// certificate.
+	InsecureSkipVerify bool
+
+	// MaxRetries is the maximum number of times a request which failed with a
+	// transient error is retried.
+	MaxRetries int
+	// RetryMaxWait is the upper bound of the time waited between two attempts
+	// of the same request.
+	RetryMaxWait time.Duration
+
+	// MaxConcurrentRequests is the maximum number of requests in flight at any
+	// time. Zero means unlimited.
+	MaxConcurrentRequests int
+
+	// ReadOnly causes all requests which may modify resources to be rejected.
+	ReadOnly bool
+
+	// Secrets are strings, such as credentials, which are masked in the log
+	// entries emitted for each request.
+	Secrets []string
+
+	// Tracer, when set, records a span for each request.
+	Tracer trace.Tracer
+
+	// Emulator, when set, serves all requests instead of the Unikraft Cloud
+	// API.
+	Emulator http.RoundTripper
+}
+
+// newHTTPClient returns an HTTP client configured according to cfg.
+func newHTTPClient(cfg httpClientConfig) (*http.Client, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	if len(cfg.CACertPEM) > 0 || cfg.InsecureSkipVerify {
+		tlsCfg := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+
+		if len(cfg.CACertPEM) > 0 {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(cfg.CACertPEM) {
+				return nil, errors.New("no valid PEM-encoded certificate found in CA bundle")
+			}
+			tlsCfg.RootCAs = pool
+		}
+
+		base.TLSClientConfig = tlsCfg
+	}
+
+	var rt http.RoundTripper = base
+
+	switch {
+	case cfg.Emulator != nil:
+		rt = cfg.Emulator
+	case cfg.Endpoint != "":
+		u, err := parseEndpoint(cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		rt = &endpointTransport{endpoint: u, next: rt}
+	}
+
+	rt = &apiErrorTransport{next: rt}
+	rt = &loggingTransport{secrets: cfg.Secrets, next: rt}
+
+	// The limit applies to individual attempts rather than to whole retried
+	// requests, so that requests waiting to be retried do not occupy a slot.
+	if cfg.MaxConcurrentRequests > 0 {
+		rt = newLimitTransport(cfg.MaxConcurrentRequests, rt)
+	}
+
+	if cfg.MaxRetries > 0 {
+		rt = &retryTransport{
+			maxRetries: cfg.MaxRetries,
+			maxWait:    cfg.RetryMaxWait,
+			next:       rt,
+		}
+	}
+
+	if cfg.ReadOnly {
+		rt = &readOnlyTransport{next: rt}
+	}
+
+	if cfg.Tracer != nil {
+		rt = &tracingTransport{tracer: cfg.Tracer, next: rt}
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+// parseEndpoint parses the given API endpoint, which must be an absolute HTTP
+// or HTTPS URL.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint URL %q must use the http or https scheme", endpoint)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("endpoint URL %q has no host", endpoint)
+	}
+	return u, nil
+}
+
+// endpointTransport is a http.RoundTripper which sends all requests to a fixed
+// API endpoint instead of the metro-derived URL built by the SDK.
+type endpointTransport struct {
+	endpoint *url.URL
+	next     http.RoundTripper
+}
+
+var _ http.RoundTripper = (*endpointTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the original request.
+	req = req.Clone(req.Context())
+
+	req.URL.Scheme = t.endpoint.Scheme
+	req.URL.Host = t.endpoint.Host
+	req.URL.Path = strings.TrimSuffix(t.endpoint.Path, "/") + req.URL.Path
+	req.URL.RawPath = ""
+	req.Host = ""
+
+	return t.next.RoundTrip(req)
+}
+
+// readResponseBody reads the whole body of resp and restores it, so that it
+// remains available to the SDK.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	return b, err
+}
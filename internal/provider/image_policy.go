@@ -0,0 +1,77 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// imagePolicy restricts the images which instances can be created from.
+//
+// A nil *imagePolicy allows all images.
+type imagePolicy struct {
+	patterns []string
+}
+
+// newImagePolicy returns an imagePolicy which allows the images matching any
+// of the given patterns. A pattern is either a glob, as supported by
+// path.Match, or a prefix made of a registry and/or namespace, such as
+// "myuser.unikraft.io/".
+func newImagePolicy(patterns ...string) (*imagePolicy, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid image pattern %q: %w", p, err)
+		}
+	}
+
+	return &imagePolicy{patterns: patterns}, nil
+}
+
+// Allows returns whether the given image matches any pattern of the policy.
+func (p *imagePolicy) Allows(image string) bool {
+	if p == nil {
+		return true
+	}
+
+	for _, pat := range p.patterns {
+		if isGlob(pat) {
+			if ok, _ := path.Match(pat, image); ok {
+				return true
+			}
+			continue
+		}
+
+		if matchesImagePrefix(image, pat) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesImagePrefix returns whether the given image is located under the
+// registry or namespace designated by prefix. The prefix must end at the
+// boundary of a path component, so that the prefix "myuser" does not match
+// the image "myuser2/app".
+func matchesImagePrefix(image, prefix string) bool {
+	if !strings.HasPrefix(image, prefix) {
+		return false
+	}
+	if len(image) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+
+	switch image[len(prefix)] {
+	case '/', ':', '@':
+		return true
+	}
+	return false
+}
+
+// isGlob returns whether the given pattern contains any glob meta-character.
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
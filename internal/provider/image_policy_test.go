@@ -0,0 +1,87 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+)
+
+func TestImagePolicyAllows(t *testing.T) {
+	testCases := map[string]struct {
+		patterns []string
+		image    string
+		want     bool
+	}{
+		"namespace prefix": {
+			patterns: []string{"myuser.unikraft.io/"},
+			image:    "myuser.unikraft.io/myapp:latest",
+			want:     true,
+		},
+		"other namespace": {
+			patterns: []string{"myuser.unikraft.io/"},
+			image:    "otheruser.unikraft.io/myapp:latest",
+		},
+		"repository prefix with tag": {
+			patterns: []string{"myuser.unikraft.io/myapp"},
+			image:    "myuser.unikraft.io/myapp:latest",
+			want:     true,
+		},
+		"repository prefix with digest": {
+			patterns: []string{"myuser.unikraft.io/myapp"},
+			image:    "myuser.unikraft.io/myapp@sha256:abc",
+			want:     true,
+		},
+		"exact image": {
+			patterns: []string{"myuser.unikraft.io/myapp"},
+			image:    "myuser.unikraft.io/myapp",
+			want:     true,
+		},
+		"prefix ends within a component": {
+			patterns: []string{"myuser.unikraft.io/myapp"},
+			image:    "myuser.unikraft.io/myapp2:latest",
+		},
+		"glob": {
+			patterns: []string{"myuser.unikraft.io/*:latest"},
+			image:    "myuser.unikraft.io/myapp:latest",
+			want:     true,
+		},
+		"glob does not cross components": {
+			patterns: []string{"myuser.unikraft.io/*"},
+			image:    "myuser.unikraft.io/team/myapp",
+		},
+		"any pattern": {
+			patterns: []string{"otheruser.unikraft.io/", "myuser.unikraft.io/"},
+			image:    "myuser.unikraft.io/myapp",
+			want:     true,
+		},
+		"no patterns": {
+			image: "myuser.unikraft.io/myapp",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p, err := newImagePolicy(tc.patterns...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := p.Allows(tc.image); got != tc.want {
+				t.Errorf("Allows(%q) = %t; expected %t", tc.image, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("nil policy", func(t *testing.T) {
+		var p *imagePolicy
+		if !p.Allows("anything") {
+			t.Error("expected a nil policy to allow all images")
+		}
+	})
+}
+
+func TestNewImagePolicyInvalidPattern(t *testing.T) {
+	if _, err := newImagePolicy("myuser.unikraft.io/[app"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// registryDomain is the domain of the Unikraft Cloud registry, under which
+// each account owns a namespace.
+const registryDomain = "unikraft.io"
+
+// namespaceFromToken returns the registry namespace of the account which owns
+// the given API token, or an empty string if it can not be determined.
+//
+// API tokens are the base64 encoding of "robot$<user>.users.kraftcloud:<secret>".
+func namespaceFromToken(token string) string {
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return ""
+	}
+
+	creds, _, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return ""
+	}
+
+	user, ok := strings.CutPrefix(creds, "robot$")
+	if !ok {
+		return ""
+	}
+	user, ok = strings.CutSuffix(user, ".users.kraftcloud")
+	if !ok || user == "" {
+		return ""
+	}
+
+	return namespaceFromUser(user)
+}
+
+// namespaceFromUser returns the registry namespace of the given user.
+func namespaceFromUser(user string) string {
+	if strings.HasSuffix(user, "."+registryDomain) {
+		return user
+	}
+	return user + "." + registryDomain
+}
+
+// normalizeImage qualifies the given image reference with the given registry
+// namespace if it does not already designate a registry, e.g. "myapp:latest"
+// becomes "myuser.unikraft.io/myapp:latest". The reference is returned as is
+// if namespace is empty.
+func normalizeImage(image, namespace string) string {
+	if namespace == "" || image == "" {
+		return image
+	}
+
+	// Same heuristic as Docker: the first component of the reference is a
+	// registry if it looks like a host name.
+	first, _, hasSlash := strings.Cut(image, "/")
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+
+	return namespace + "/" + image
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestNamespaceFromToken(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	testCases := map[string]struct {
+		token string
+		want  string
+	}{
+		"valid token": {
+			token: encode("robot$myuser.users.kraftcloud:secret"),
+			want:  "myuser.unikraft.io",
+		},
+		"qualified user": {
+			token: encode("robot$myuser.unikraft.io.users.kraftcloud:secret"),
+			want:  "myuser.unikraft.io",
+		},
+		"secret with colons": {
+			token: encode("robot$myuser.users.kraftcloud:a:b"),
+			want:  "myuser.unikraft.io",
+		},
+		"not base64": {
+			token: "not a token!",
+		},
+		"no secret": {
+			token: encode("robot$myuser.users.kraftcloud"),
+		},
+		"no robot prefix": {
+			token: encode("myuser.users.kraftcloud:secret"),
+		},
+		"no users suffix": {
+			token: encode("robot$myuser:secret"),
+		},
+		"empty user": {
+			token: encode("robot$.users.kraftcloud:secret"),
+		},
+		"empty": {
+			token: "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := namespaceFromToken(tc.token); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	const namespace = "myuser.unikraft.io"
+
+	testCases := map[string]struct {
+		image     string
+		namespace string
+		want      string
+	}{
+		"short reference": {
+			image:     "myapp:latest",
+			namespace: namespace,
+			want:      "myuser.unikraft.io/myapp:latest",
+		},
+		"short reference with digest": {
+			image:     "myapp@sha256:abc",
+			namespace: namespace,
+			want:      "myuser.unikraft.io/myapp@sha256:abc",
+		},
+		"nested repository": {
+			image:     "team/myapp",
+			namespace: namespace,
+			want:      "myuser.unikraft.io/team/myapp",
+		},
+		"qualified reference": {
+			image:     "otheruser.unikraft.io/myapp:latest",
+			namespace: namespace,
+			want:      "otheruser.unikraft.io/myapp:latest",
+		},
+		"registry with port": {
+			image:     "registry:5000/myapp",
+			namespace: namespace,
+			want:      "registry:5000/myapp",
+		},
+		"localhost": {
+			image:     "localhost/myapp",
+			namespace: namespace,
+			want:      "localhost/myapp",
+		},
+		"no namespace": {
+			image: "myapp:latest",
+			want:  "myapp:latest",
+		},
+		"empty image": {
+			namespace: namespace,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := normalizeImage(tc.image, tc.namespace); got != tc.want {
+				t.Errorf("normalizeImage(%q, %q) = %q; expected %q", tc.image, tc.namespace, got, tc.want)
+			}
+		})
+	}
+}
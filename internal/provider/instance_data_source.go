@@ -10,9 +10,10 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-
-	"sdk.kraft.cloud/instances"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewInstanceDataSource() datasource.DataSource {
@@ -21,15 +22,20 @@ func NewInstanceDataSource() datasource.DataSource {
 
 // InstanceDataSource defines the data source implementation.
 type InstanceDataSource struct {
-	client instances.InstancesService
+	clients *clientPool
+	tracer  trace.Tracer
 }
 
 // Ensure InstanceDataSource satisfies various datasource interfaces.
 var _ datasource.DataSource = &InstanceDataSource{}
 
+// instanceDataSourceType is the full type name of the instance data source.
+const instanceDataSourceType = "unikraft-cloud_instance"
+
 // InstanceDataSourceModel describes the data source data model.
 type InstanceDataSourceModel struct {
-	UUID types.String `tfsdk:"uuid"`
+	Metro types.String `tfsdk:"metro"`
+	UUID  types.String `tfsdk:"uuid"`
 
 	Name              types.String `tfsdk:"name"`
 	FQDN              types.String `tfsdk:"fqdn"`
@@ -58,6 +64,11 @@ func (d *InstanceDataSource) Schema(ctx context.Context, req datasource.SchemaRe
 		MarkdownDescription: "Provides state information about a Unikraft Cloud instance.",
 
 		Attributes: map[string]schema.Attribute{
+			"metro": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Metro in which the instance is located. Defaults to the metro configured in the provider.",
+			},
 			"uuid": schema.StringAttribute{
 				Required: true,
 				MarkdownDescription: "Unique identifier of the " +
@@ -156,20 +167,28 @@ func (d *InstanceDataSource) Configure(ctx context.Context, req datasource.Confi
 		return
 	}
 
-	client, ok := req.ProviderData.(instances.InstancesService)
+	pdata, ok := req.ProviderData.(*providerData)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected instances.InstancesServices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	d.client = client
+	d.clients = pdata.clients
+	d.tracer = pdata.tracer
 }
 
 // Read implements datasource.DataSource.
 func (d *InstanceDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := startSpan(ctx, d.tracer, "data."+instanceDataSourceType+".Read",
+		spanAttrDataSourceType.String(instanceDataSourceType),
+	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
 	var data InstanceDataSourceModel
 
 	// Read Terraform configuration data into the model
@@ -178,15 +197,29 @@ func (d *InstanceDataSource) Read(ctx context.Context, req datasource.ReadReques
 		return
 	}
 
-	insRaw, err := d.client.Get(ctx, data.UUID.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to get instance state, got error: %v", err),
+	data.Metro = types.StringValue(d.clients.Metro(data.Metro))
+
+	if err := d.clients.ValidateMetro(data.Metro.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("metro"),
+			"Invalid Unikraft Cloud API Metro",
+			"The configured metro is invalid: "+err.Error(),
 		)
 		return
 	}
-	ins := insRaw.Data.Entries[0]
+
+	ctx = tflog.SetField(ctx, logFieldMetro, data.Metro.ValueString())
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, data.UUID.ValueString())
+	span.SetAttributes(
+		spanAttrMetro.String(data.Metro.ValueString()),
+		spanAttrInstanceUUID.String(data.UUID.ValueString()),
+	)
+
+	ins, err := d.clients.InstanceGetter(data.Metro.ValueString()).Get(ctx, data.UUID.ValueString())
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
+		return
+	}
 
 	var diags diag.Diagnostics
 
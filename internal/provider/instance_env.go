@@ -0,0 +1,205 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// privateKeyEnvKeys is the private state key which holds the names of the
+// variables of the env attribute of an instance which were set by its
+// configuration, as a JSON array.
+const privateKeyEnvKeys = "env_keys"
+
+// privateState is the private state of a resource, as exposed by the
+// framework to plan modifiers and to resource operations.
+type privateState interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+}
+
+// effectiveEnv merges the variables of the given layers, the ones of later
+// layers taking precedence, and drops the variables set by any of the
+// excluded maps. The result is unknown if any of the maps is not fully known.
+func effectiveEnv(ctx context.Context, layers []types.Map, excluded ...types.Map) (types.Map, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	for _, m := range slices.Concat(layers, excluded) {
+		if m.IsUnknown() {
+			return types.MapUnknown(types.StringType), diags
+		}
+		for _, v := range m.Elements() {
+			if v.IsUnknown() {
+				return types.MapUnknown(types.StringType), diags
+			}
+		}
+	}
+
+	env := make(map[string]string)
+	for _, m := range layers {
+		if m.IsNull() {
+			continue
+		}
+		vars := make(map[string]string, len(m.Elements()))
+		diags.Append(m.ElementsAs(ctx, &vars, false)...)
+		maps.Copy(env, vars)
+	}
+	for _, m := range excluded {
+		for k := range m.Elements() {
+			delete(env, k)
+		}
+	}
+
+	effective, d := types.MapValueFrom(ctx, types.StringType, env)
+	diags.Append(d...)
+	return effective, diags
+}
+
+// envRequiresReplace requires the replacement of instances when the configured
+// env or sensitive_env attribute changes.
+//
+// The env attribute holds variables which were not configured, such as the
+// ones injected by the platform, the provider's default variables, or all
+// variables of imported instances until their first update. Setting those
+// variables to their current value in the configuration does therefore not
+// require a replacement; see envChangeRequiresReplace.
+func envRequiresReplace(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+	if req.ConfigValue.IsNull() {
+		// Unlike sensitive_env, env keeps its prior value when unset.
+		resp.RequiresReplace = !req.Path.Equal(path.Root("env"))
+		return
+	}
+
+	imported, diags := req.Private.GetKey(ctx, privateKeyImported)
+	resp.Diagnostics.Append(diags...)
+
+	// The variables of imported instances, including the sensitive ones, are
+	// all part of their env attribute.
+	var prior types.Map
+	var configured []string
+	switch {
+	case imported != nil:
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("env"), &prior)...)
+	case req.Path.Equal(path.Root("env")):
+		prior = req.StateValue
+		configured, diags = configuredEnvKeys(ctx, req.Private, prior)
+		resp.Diagnostics.Append(diags...)
+	default:
+		// sensitive_env only holds configured variables.
+		resp.RequiresReplace = true
+		return
+	}
+
+	resp.RequiresReplace = envChangeRequiresReplace(prior, req.ConfigValue, configured)
+}
+
+// envChangeRequiresReplace returns whether an instance which environment holds
+// the variables of prior must be replaced to apply the configured variables.
+// configured holds the names of the variables of prior which were set by the
+// configuration.
+//
+// Only the configured variables are compared: variables which are newly set
+// to their current value do not require a replacement, but configured
+// variables which are removed do.
+func envChangeRequiresReplace(prior, config types.Map, configured []string) bool {
+	if config.IsUnknown() || !containsEnv(prior, config) {
+		return true
+	}
+
+	elems := config.Elements()
+	for _, k := range configured {
+		if _, ok := elems[k]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredEnvKeys returns the names of the variables of the env attribute
+// of an instance which were set by its configuration, as recorded in its
+// private state. Instances created before those names were recorded are
+// assumed to have all the variables of prior configured.
+func configuredEnvKeys(ctx context.Context, private privateState, prior types.Map) ([]string, diag.Diagnostics) {
+	b, diags := private.GetKey(ctx, privateKeyEnvKeys)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	if b == nil {
+		return envKeys(prior), diags
+	}
+
+	var keys []string
+	if err := json.Unmarshal(b, &keys); err != nil {
+		diags.AddError(
+			"Invalid Private State",
+			"The names of the configured environment variables of the instance cannot be decoded: "+err.Error(),
+		)
+		return nil, diags
+	}
+	return keys, diags
+}
+
+// envKeysPrivateState returns the value of the private state key which
+// records the names of the variables of the given configured env attribute.
+func envKeysPrivateState(config types.Map) []byte {
+	b, _ := json.Marshal(envKeys(config))
+	return b
+}
+
+// envKeys returns the sorted names of the variables of env.
+func envKeys(env types.Map) []string {
+	keys := make([]string, 0, len(env.Elements()))
+	for k := range env.Elements() {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// envFileRequiresReplace returns whether an instance must be replaced to
+// apply the variables planned from its environment file, given the ones of
+// the prior state. importedEnv holds the variables of imported instances,
+// which were not loaded from the file yet, and is null otherwise.
+func envFileRequiresReplace(planned, prior, importedEnv types.Map) bool {
+	if prior.IsNull() && !importedEnv.IsNull() {
+		return !containsEnv(importedEnv, planned)
+	}
+	return !planned.Equal(prior)
+}
+
+// containsEnv returns whether all variables of sub are set to the same values
+// in env.
+func containsEnv(env, sub types.Map) bool {
+	elems := env.Elements()
+	for k, v := range sub.Elements() {
+		if e, ok := elems[k]; !ok || !e.Equal(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// knownEnv returns the variables of the actual environment of an instance
+// which are among the keys of known. Variables injected by the platform must
+// not cause differences with the configuration, therefore only the variables
+// already known are refreshed. A variable which was removed outside of
+// Terraform is reported as a difference.
+func knownEnv(known types.Map, actual map[string]string) map[string]string {
+	env := make(map[string]string, len(known.Elements()))
+	for k := range known.Elements() {
+		if v, ok := actual[k]; ok {
+			env[k] = v
+		}
+	}
+	return env
+}
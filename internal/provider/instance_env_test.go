@@ -0,0 +1,299 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// envMap returns a map attribute value holding the given variables.
+func envMap(vars map[string]string) types.Map {
+	elems := make(map[string]attr.Value, len(vars))
+	for k, v := range vars {
+		elems[k] = types.StringValue(v)
+	}
+	return types.MapValueMust(types.StringType, elems)
+}
+
+// fakePrivateState is a privateState backed by a map.
+type fakePrivateState map[string][]byte
+
+// GetKey implements privateState.
+func (f fakePrivateState) GetKey(_ context.Context, key string) ([]byte, diag.Diagnostics) {
+	return f[key], nil
+}
+
+func TestEnvChangeRequiresReplace(t *testing.T) {
+	// prior is the env attribute of an instance created with A configured,
+	// and P injected by the platform.
+	prior := envMap(map[string]string{"A": "1", "P": "injected"})
+	configured := []string{"A"}
+
+	testCases := map[string]struct {
+		prior      types.Map
+		config     types.Map
+		configured []string
+		want       bool
+	}{
+		"unchanged": {
+			prior:      prior,
+			config:     envMap(map[string]string{"A": "1"}),
+			configured: configured,
+		},
+		"added variable": {
+			prior:      prior,
+			config:     envMap(map[string]string{"A": "1", "B": "2"}),
+			configured: configured,
+			want:       true,
+		},
+		"changed variable": {
+			prior:      prior,
+			config:     envMap(map[string]string{"A": "2"}),
+			configured: configured,
+			want:       true,
+		},
+		"removed variable": {
+			prior:      prior,
+			config:     envMap(map[string]string{}),
+			configured: configured,
+			want:       true,
+		},
+		"overlapping variable with the same value": {
+			prior:      prior,
+			config:     envMap(map[string]string{"A": "1", "P": "injected"}),
+			configured: configured,
+		},
+		"overlapping variable with another value": {
+			prior:      prior,
+			config:     envMap(map[string]string{"A": "1", "P": "other"}),
+			configured: configured,
+			want:       true,
+		},
+		"variables of an imported instance": {
+			prior:  envMap(map[string]string{"A": "1", "B": "2", "P": "injected"}),
+			config: envMap(map[string]string{"A": "1", "B": "2"}),
+		},
+		"unknown configuration": {
+			prior:      prior,
+			config:     types.MapUnknown(types.StringType),
+			configured: configured,
+			want:       true,
+		},
+		"unknown value": {
+			prior: prior,
+			config: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"A": types.StringUnknown(),
+			}),
+			configured: configured,
+			want:       true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := envChangeRequiresReplace(tc.prior, tc.config, tc.configured); got != tc.want {
+				t.Errorf("envChangeRequiresReplace() = %t; expected %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfiguredEnvKeys(t *testing.T) {
+	ctx := context.Background()
+	prior := envMap(map[string]string{"B": "2", "A": "1"})
+
+	testCases := map[string]struct {
+		private fakePrivateState
+		want    []string
+	}{
+		"recorded": {
+			private: fakePrivateState{privateKeyEnvKeys: envKeysPrivateState(envMap(map[string]string{"A": "1"}))},
+			want:    []string{"A"},
+		},
+		"recorded without configured variables": {
+			private: fakePrivateState{privateKeyEnvKeys: envKeysPrivateState(types.MapNull(types.StringType))},
+			want:    []string{},
+		},
+		"not recorded": {
+			private: fakePrivateState{},
+			want:    []string{"A", "B"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, diags := configuredEnvKeys(ctx, tc.private, prior)
+			if diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("configuredEnvKeys() = %q; expected %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, diags := configuredEnvKeys(ctx, fakePrivateState{privateKeyEnvKeys: []byte(`{}`)}, prior)
+		if !diags.HasError() {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestKnownEnv(t *testing.T) {
+	actual := map[string]string{"A": "changed", "P": "injected"}
+
+	got := knownEnv(envMap(map[string]string{"A": "1", "B": "2"}), actual)
+
+	// B was removed outside of Terraform, and P is not known.
+	if len(got) != 1 || got["A"] != "changed" {
+		t.Errorf("knownEnv() = %v; expected only A with its actual value", got)
+	}
+}
+
+func TestEffectiveEnv(t *testing.T) {
+	ctx := context.Background()
+	null := types.MapNull(types.StringType)
+	defaults := envMap(map[string]string{"A": "default", "B": "default", "C": "default", "D": "default"})
+	envFileVars := envMap(map[string]string{"B": "file", "C": "file"})
+	env := envMap(map[string]string{"C": "env"})
+
+	testCases := map[string]struct {
+		layers   []types.Map
+		excluded []types.Map
+		want     types.Map
+	}{
+		"defaults, then file, then env": {
+			layers: []types.Map{defaults, envFileVars, env},
+			want:   envMap(map[string]string{"A": "default", "B": "file", "C": "env", "D": "default"}),
+		},
+		"excluded variables": {
+			layers:   []types.Map{defaults, envFileVars, env},
+			excluded: []types.Map{envMap(map[string]string{"C": "sensitive"}), envMap(map[string]string{"D": "write-only"})},
+			want:     envMap(map[string]string{"A": "default", "B": "file"}),
+		},
+		"null layers": {
+			layers:   []types.Map{null, null, env},
+			excluded: []types.Map{null},
+			want:     envMap(map[string]string{"C": "env"}),
+		},
+		"no variables": {
+			layers: []types.Map{null},
+			want:   envMap(map[string]string{}),
+		},
+		"unknown layer": {
+			layers: []types.Map{defaults, types.MapUnknown(types.StringType), env},
+			want:   types.MapUnknown(types.StringType),
+		},
+		"unknown value": {
+			layers: []types.Map{defaults, types.MapValueMust(types.StringType, map[string]attr.Value{
+				"B": types.StringUnknown(),
+			})},
+			want: types.MapUnknown(types.StringType),
+		},
+		"unknown excluded value": {
+			layers: []types.Map{defaults},
+			excluded: []types.Map{types.MapValueMust(types.StringType, map[string]attr.Value{
+				"A": types.StringUnknown(),
+			})},
+			want: types.MapUnknown(types.StringType),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, diags := effectiveEnv(ctx, tc.layers, tc.excluded...)
+			if diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("effectiveEnv() = %v; expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnvFileRequiresReplace(t *testing.T) {
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), ".env")
+
+	// load writes the file with the given content and returns its variables
+	// as planned.
+	load := func(content string) types.Map {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		vars, err := readDotenvFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, diags := types.MapValueFrom(ctx, types.StringType, vars)
+		if diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+		return m
+	}
+
+	const content = "A=1\nB=2\n"
+	prior := load(content)
+	null := types.MapNull(types.StringType)
+
+	testCases := map[string]struct {
+		planned     types.Map
+		prior       types.Map
+		importedEnv types.Map
+		want        bool
+	}{
+		"unchanged file": {
+			planned:     load(content),
+			prior:       prior,
+			importedEnv: null,
+		},
+		"reordered file": {
+			planned:     load("B=2\n# comment\nA=1\n"),
+			prior:       prior,
+			importedEnv: null,
+		},
+		"changed file": {
+			planned:     load("A=1\nB=3\n"),
+			prior:       prior,
+			importedEnv: null,
+			want:        true,
+		},
+		"new file": {
+			planned:     load(content),
+			prior:       null,
+			importedEnv: null,
+			want:        true,
+		},
+		"imported instance with the variables": {
+			planned:     load(content),
+			prior:       null,
+			importedEnv: envMap(map[string]string{"A": "1", "B": "2", "P": "injected"}),
+		},
+		"imported instance with other variables": {
+			planned:     load(content),
+			prior:       null,
+			importedEnv: envMap(map[string]string{"A": "1", "B": "other"}),
+			want:        true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := envFileRequiresReplace(tc.planned, tc.prior, tc.importedEnv); got != tc.want {
+				t.Errorf("envFileRequiresReplace() = %t; expected %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Naming rules of instances, which names are used as DNS labels.
+const (
+	maxInstanceNameLength = 63
+	// instanceNameSuffixLength is the length of the random suffix appended to
+	// the name prefix of instances.
+	instanceNameSuffixLength = 8
+)
+
+var (
+	// instanceNameRe matches valid instance names.
+	instanceNameRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	// instanceNamePrefixRe matches valid prefixes of instance names.
+	instanceNamePrefixRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// instanceName returns the name with which an instance is created: its
+// planned name if known, a name generated from its name prefix, or nil to
+// have the platform generate one.
+func instanceName(name, namePrefix types.String) (*string, error) {
+	switch {
+	case !name.IsUnknown() && !name.IsNull():
+		return ptr(name.ValueString()), nil
+	case !namePrefix.IsNull():
+		n, err := randomInstanceName(namePrefix.ValueString())
+		if err != nil {
+			return nil, err
+		}
+		return &n, nil
+	default:
+		return nil, nil
+	}
+}
+
+// randomInstanceName returns an instance name made of the given prefix and a
+// random suffix.
+func randomInstanceName(prefix string) (string, error) {
+	b := make([]byte, instanceNameSuffixLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
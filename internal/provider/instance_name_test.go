@@ -0,0 +1,214 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestRandomInstanceName(t *testing.T) {
+	suffixRe := regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+	testCases := map[string]string{
+		"short prefix":   "a",
+		"prefix":         "app-",
+		"longest prefix": strings.Repeat("a", maxInstanceNameLength-instanceNameSuffixLength),
+	}
+
+	for name, prefix := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := randomInstanceName(prefix)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			suffix, ok := strings.CutPrefix(got, prefix)
+			if !ok || !suffixRe.MatchString(suffix) {
+				t.Errorf("randomInstanceName(%q) = %q; expected the prefix and %d hexadecimal digits",
+					prefix, got, instanceNameSuffixLength)
+			}
+			if !instanceNameRe.MatchString(got) || len(got) > maxInstanceNameLength {
+				t.Errorf("randomInstanceName(%q) = %q; expected a valid instance name", prefix, got)
+			}
+		})
+	}
+
+	t.Run("unique", func(t *testing.T) {
+		const n = 1000
+		seen := make(map[string]bool, n)
+		for range n {
+			got, err := randomInstanceName("app-")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if seen[got] {
+				t.Fatalf("randomInstanceName() returned %q twice", got)
+			}
+			seen[got] = true
+		}
+	})
+}
+
+func TestInstanceName(t *testing.T) {
+	null := types.StringNull()
+	unknown := types.StringUnknown()
+
+	testCases := map[string]struct {
+		name       types.String
+		namePrefix types.String
+		want       *regexp.Regexp
+	}{
+		"planned name": {
+			name:       types.StringValue("app"),
+			namePrefix: null,
+			want:       regexp.MustCompile(`^app$`),
+		},
+		"name prefix": {
+			name:       unknown,
+			namePrefix: types.StringValue("app-"),
+			want:       regexp.MustCompile(`^app-[0-9a-f]{8}$`),
+		},
+		"generated by the platform": {
+			name:       unknown,
+			namePrefix: null,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := instanceName(tc.name, tc.namePrefix)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch {
+			case tc.want == nil && got != nil:
+				t.Errorf("instanceName() = %q; expected none", *got)
+			case tc.want != nil && (got == nil || !tc.want.MatchString(*got)):
+				t.Errorf("instanceName() = %v; expected a name matching %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// planInstanceName runs the plan modifiers of the given string attribute of
+// the instance resource when updating an instance, and returns the planned
+// value and whether the instance must be replaced.
+func planInstanceName(t *testing.T, attr string, config, state types.String) (types.String, bool) {
+	t.Helper()
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	(&InstanceResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	a, ok := schemaResp.Schema.Attributes[attr].(schema.StringAttribute)
+	if !ok {
+		t.Fatalf("attribute %q is not a string attribute", attr)
+	}
+
+	// Computed attributes which are not configured are unknown in the plan
+	// until they are modified.
+	plan := config
+	if config.IsNull() && a.Computed {
+		plan = types.StringUnknown()
+	}
+
+	// Non-null raw values mark the plan as an update.
+	raw := tftypes.NewValue(tftypes.Object{}, map[string]tftypes.Value{})
+	req := planmodifier.StringRequest{
+		Path:        path.Root(attr),
+		Config:      tfsdk.Config{Raw: raw},
+		ConfigValue: config,
+		Plan:        tfsdk.Plan{Raw: raw},
+		PlanValue:   plan,
+		State:       tfsdk.State{Raw: raw},
+		StateValue:  state,
+	}
+
+	var requiresReplace bool
+	for _, m := range a.PlanModifiers {
+		resp := &planmodifier.StringResponse{PlanValue: req.PlanValue}
+		m.PlanModifyString(ctx, req, resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("unexpected error: %v", resp.Diagnostics)
+		}
+		req.PlanValue = resp.PlanValue
+		requiresReplace = requiresReplace || resp.RequiresReplace
+	}
+	return req.PlanValue, requiresReplace
+}
+
+func TestInstanceNamePlan(t *testing.T) {
+	// generated is the name of an instance created with the app- name prefix,
+	// or without a name.
+	generated := types.StringValue("app-0a1b2c3d")
+
+	testCases := map[string]struct {
+		attr                string
+		config              types.String
+		state               types.String
+		want                types.String
+		wantRequiresReplace bool
+	}{
+		"generated name": {
+			attr:   "name",
+			config: types.StringNull(),
+			state:  generated,
+			want:   generated,
+		},
+		"unchanged name": {
+			attr:   "name",
+			config: types.StringValue("app"),
+			state:  types.StringValue("app"),
+			want:   types.StringValue("app"),
+		},
+		"changed name": {
+			attr:                "name",
+			config:              types.StringValue("other"),
+			state:               types.StringValue("app"),
+			want:                types.StringValue("other"),
+			wantRequiresReplace: true,
+		},
+		"configured generated name": {
+			attr:   "name",
+			config: generated,
+			state:  generated,
+			want:   generated,
+		},
+		"unchanged name prefix": {
+			attr:   "name_prefix",
+			config: types.StringValue("app-"),
+			state:  types.StringValue("app-"),
+			want:   types.StringValue("app-"),
+		},
+		"changed name prefix": {
+			attr:                "name_prefix",
+			config:              types.StringValue("other-"),
+			state:               types.StringValue("app-"),
+			want:                types.StringValue("other-"),
+			wantRequiresReplace: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, requiresReplace := planInstanceName(t, tc.attr, tc.config, tc.state)
+			if !got.Equal(tc.want) {
+				t.Errorf("planned %s = %s; expected %s", tc.attr, got, tc.want)
+			}
+			if requiresReplace != tc.wantRequiresReplace {
+				t.Errorf("requires replace = %t; expected %t", requiresReplace, tc.wantRequiresReplace)
+			}
+		})
+	}
+}
@@ -5,10 +5,15 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
+	"slices"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -16,11 +21,14 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/trace"
 
 	"sdk.kraft.cloud/instances"
 	"sdk.kraft.cloud/services"
@@ -32,30 +40,67 @@ func NewInstanceResource() resource.Resource {
 
 // InstanceResource defines the resource implementation.
 type InstanceResource struct {
-	client instances.InstancesService
+	clients   *clientPool
+	defaults  *instanceDefaultsModel
+	readOnly  bool
+	audit     *auditLogger
+	tracer    trace.Tracer
+	images    *imagePolicy
+	namespace string
+	creates   *operationLimit
+	deletes   *operationLimit
 }
 
 // Ensure InstanceResource satisfies various resource interfaces.
 var (
 	_ resource.Resource                = &InstanceResource{}
 	_ resource.ResourceWithImportState = &InstanceResource{}
+	_ resource.ResourceWithModifyPlan  = &InstanceResource{}
+
+	_ resource.ResourceWithValidateConfig = &InstanceResource{}
 )
 
+// instanceResourceType is the full type name of the instance resource.
+const instanceResourceType = "unikraft-cloud_instance"
+
+// privateKeyImported is the private state key which marks instances that were
+// imported and not updated since.
+const privateKeyImported = "imported"
+
+// restartPolicies are the accepted values of an instance's restart policy.
+var restartPolicies = []string{
+	"never",
+	"always",
+	"on-failure",
+}
+
 // InstanceResourceModel describes the resource data model.
 type InstanceResourceModel struct {
+	Metro     types.String `tfsdk:"metro"`
 	Image     types.String `tfsdk:"image"`
 	Args      types.List   `tfsdk:"args"`
 	MemoryMB  types.Int64  `tfsdk:"memory_mb"`
 	Autostart types.Bool   `tfsdk:"autostart"`
 
+	RestartPolicy types.String `tfsdk:"restart_policy"`
+	DesiredState  types.String `tfsdk:"desired_state"`
+
 	UUID              types.String `tfsdk:"uuid"`
 	Name              types.String `tfsdk:"name"`
+	NamePrefix        types.String `tfsdk:"name_prefix"`
 	FQDN              types.String `tfsdk:"fqdn"`
 	PrivateIP         types.String `tfsdk:"private_ip"`
 	PrivateFQDN       types.String `tfsdk:"private_fqdn"`
 	State             types.String `tfsdk:"state"`
 	CreatedAt         types.String `tfsdk:"created_at"`
 	Env               types.Map    `tfsdk:"env"`
+	EnvFile           types.String `tfsdk:"env_file"`
+	EnvFileVars       types.Map    `tfsdk:"env_file_vars"`
+	EffectiveEnv      types.Map    `tfsdk:"effective_env"`
+	DefaultEnv        types.Map    `tfsdk:"default_env"`
+	SensitiveEnv      types.Map    `tfsdk:"sensitive_env"`
+	EnvWO             types.Map    `tfsdk:"env_wo"`
+	EnvWOVersion      types.Int64  `tfsdk:"env_wo_version"`
 	ServiceGroup      *svcGrpModel `tfsdk:"service_group"`
 	NetworkInterfaces types.List   `tfsdk:"network_interfaces"`
 	BootTimeUS        types.Int64  `tfsdk:"boot_time_us"`
@@ -73,8 +118,20 @@ func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaReques
 		MarkdownDescription: "Allows the creation of Unikraft Cloud instances.",
 
 		Attributes: map[string]schema.Attribute{
+			"metro": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				MarkdownDescription: "Metro in which the instance is created. Defaults to the metro configured " +
+					"in the provider.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplaceIfConfigured(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
 			"image": schema.StringAttribute{
 				Required: true,
+				MarkdownDescription: "Image of the instance. Short references, such as `myapp:latest`, are " +
+					"qualified with the registry namespace of the account, e.g. `myuser.unikraft.io/myapp:latest`.",
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.RequiresReplace(),
 				},
@@ -100,11 +157,35 @@ func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaReques
 			},
 			"autostart": schema.BoolAttribute{
 				Optional: true,
+				Computed: true,
 				PlanModifiers: []planmodifier.Bool{
 					boolplanmodifier.RequiresReplaceIfConfigured(),
 					boolplanmodifier.UseStateForUnknown(),
 				},
 			},
+			"restart_policy": schema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				MarkdownDescription: "Policy applied when the instance exits. One of `never`, `always`, " +
+					"`on-failure`.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(restartPolicies...),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplaceIfConfigured(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"desired_state": schema.StringAttribute{
+				Optional: true,
+				MarkdownDescription: "State in which the instance is kept. One of `running`, `stopped`. Changes " +
+					"are applied in place by starting or stopping the instance. Instances on standby, which are " +
+					"started again on demand, are considered `running`. When unset, the state of the instance is not " +
+					"managed.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(desiredStates...),
+				},
+			},
 			"uuid": schema.StringAttribute{
 				Computed:            true,
 				MarkdownDescription: "Unique identifier of the instance",
@@ -113,7 +194,34 @@ func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaReques
 				},
 			},
 			"name": schema.StringAttribute{
+				Optional: true,
 				Computed: true,
+				MarkdownDescription: "Name of the instance, which also determines its private FQDN. Must consist of " +
+					"lower case alphanumeric characters or `-`, and start and end with an alphanumeric character. " +
+					"Generated by the platform if neither `name` nor `name_prefix` is set.",
+				Validators: []validator.String{
+					stringvalidator.LengthBetween(1, maxInstanceNameLength),
+					stringvalidator.RegexMatches(instanceNameRe, "must consist of lower case alphanumeric characters "+
+						"or '-', and start and end with an alphanumeric character"),
+					stringvalidator.ConflictsWith(path.MatchRoot("name_prefix")),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplaceIfConfigured(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional: true,
+				MarkdownDescription: fmt.Sprintf("Prefix of the name of the instance, to which a random suffix of %d "+
+					"characters is appended. Conflicts with `name`.", instanceNameSuffixLength),
+				Validators: []validator.String{
+					stringvalidator.LengthBetween(1, maxInstanceNameLength-instanceNameSuffixLength),
+					stringvalidator.RegexMatches(instanceNamePrefixRe, "must consist of lower case alphanumeric "+
+						"characters or '-', and start with an alphanumeric character"),
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 			"private_ip": schema.StringAttribute{
 				Computed: true,
@@ -128,8 +236,92 @@ func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaReques
 				Computed: true,
 			},
 			"env": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Computed:    true,
+				MarkdownDescription: "Environment variables of the instance. Variables injected by the platform " +
+					"are not tracked, except after an import until the next apply. Configuring variables which the " +
+					"instance already has, with the same values, does not replace it.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplaceIf(envRequiresReplace,
+						"If the value of this attribute is configured and changes, Terraform will destroy and recreate the resource.",
+						"If the value of this attribute is configured and changes, Terraform will destroy and recreate the resource.",
+					),
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"env_file": schema.StringAttribute{
+				Optional: true,
+				MarkdownDescription: "Path of a file in the dotenv format from which environment variables of the " +
+					"instance are loaded when planning. Variables of `env` take precedence over the ones of the file. " +
+					"The loaded variables are part of the `env_file_vars` attribute.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"env_file_vars": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Sensitive:   true,
+				MarkdownDescription: "Environment variables loaded from `env_file`. Changing them replaces the instance. " +
+					"Their values are hidden from the plan output, but stored in the state.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"effective_env": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				Sensitive:   true,
+				MarkdownDescription: "Environment variables the instance was created with: the provider's default " +
+					"variables, overridden by the ones of `env_file_vars`, overridden by the ones of `env`. Variables " +
+					"of `sensitive_env` and `env_wo` are not included. Hidden from the plan output like " +
+					"`env_file_vars`, but stored in the state. The default variables are shown by `default_env`.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"default_env": schema.MapAttribute{
 				ElementType: types.StringType,
 				Computed:    true,
+				MarkdownDescription: "Default environment variables of the provider which the instance was created " +
+					"with, i.e. the ones not overridden by `env_file_vars`, `env`, `sensitive_env` or `env_wo`.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"sensitive_env": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				MarkdownDescription: "Environment variables of the instance which values are hidden from the " +
+					"plan output. They are still stored in the state.",
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplaceIf(envRequiresReplace,
+						"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+						"If the value of this attribute changes, Terraform will destroy and recreate the resource.",
+					),
+				},
+			},
+			"env_wo": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+				WriteOnly:   true,
+				MarkdownDescription: "Environment variables of the instance which are never stored in the plan " +
+					"or state. Requires Terraform 1.11 or later. Changes are only applied when `env_wo_version` " +
+					"changes.",
+			},
+			"env_wo_version": schema.Int64Attribute{
+				Optional: true,
+				MarkdownDescription: "Version of the variables of `env_wo`. Changing it replaces the instance " +
+					"with the current values of `env_wo`.",
+				Validators: []validator.Int64{
+					int64validator.AlsoRequires(path.MatchRoot("env_wo")),
+				},
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
 			},
 			"service_group": schema.SingleNestedAttribute{
 				Required: true,
@@ -240,20 +432,188 @@ func (r *InstanceResource) Configure(ctx context.Context, req resource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(instances.InstancesService)
+	pdata, ok := req.ProviderData.(*providerData)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected instances.InstancesServices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	r.client = client
+	r.clients = pdata.clients
+	r.defaults = pdata.defaults
+	r.readOnly = pdata.readOnly
+	r.audit = pdata.audit
+	r.tracer = pdata.tracer
+	r.images = pdata.images
+	r.namespace = pdata.namespace
+	r.creates = pdata.creates
+	r.deletes = pdata.deletes
+}
+
+// ValidateConfig implements resource.ResourceWithValidateConfig.
+//
+// Each environment variable must be set by a single attribute, since the
+// environment of instances is the union of those attributes.
+func (r *InstanceResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	setBy := make(map[string]string)
+
+	for _, attr := range []string{"env", "sensitive_env", "env_wo"} {
+		var env types.Map
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(attr), &env)...)
+		if env.IsNull() || env.IsUnknown() {
+			continue
+		}
+
+		keys := make([]string, 0, len(env.Elements()))
+		for k := range env.Elements() {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			if other, ok := setBy[k]; ok {
+				resp.Diagnostics.AddAttributeError(
+					path.Root(attr).AtMapKey(k),
+					"Duplicate Environment Variable",
+					fmt.Sprintf("The environment variable %q is already set by the %s attribute.", k, other),
+				)
+				continue
+			}
+			setBy[k] = attr
+		}
+	}
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan.
+//
+// The configured metro is validated against the metros offered by the
+// platform, and the image against the provider's image policy. Settings which
+// are not configured on instances about to be created are populated from the
+// provider's defaults, so that the effective values are visible in the plan.
+func (r *InstanceResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to validate when the instance is about to be destroyed, or before
+	// the provider is configured.
+	if req.Plan.Raw.IsNull() || r.clients == nil {
+		return
+	}
+
+	var metro types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("metro"), &metro)...)
+	if !metro.IsNull() && !metro.IsUnknown() {
+		if err := r.clients.ValidateMetro(metro.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("metro"),
+				"Invalid Unikraft Cloud API Metro",
+				"The configured metro is invalid: "+err.Error(),
+			)
+		}
+	}
+
+	// The image policy only applies to instances about to be created, either
+	// initially or as a replacement, so that changing the policy does not
+	// prevent the planning of unrelated changes. Short image references are
+	// checked once qualified with the account's namespace.
+	var image, priorImage types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("image"), &image)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("image"), &priorImage)...)
+	}
+	if !image.IsNull() && !image.IsUnknown() && !image.Equal(priorImage) {
+		if ref := normalizeImage(image.ValueString(), r.namespace); !r.images.Allows(ref) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("image"),
+				"Image Not Allowed",
+				fmt.Sprintf("The image %q matches none of the patterns of the provider's allowed_image_patterns "+
+					"attribute. Use an image from an allowed registry or namespace, or update the allowed image "+
+					"patterns.", ref),
+			)
+		}
+	}
+
+	var envFile types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env_file"), &envFile)...)
+	if envFile.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("env_file_vars"), types.MapNull(types.StringType))...)
+	} else {
+		r.planEnvFile(ctx, envFile, req, resp)
+	}
+
+	// Defaults only apply to the creation of instances. Changing them does not
+	// affect existing instances.
+	if !req.State.Raw.IsNull() {
+		return
+	}
+
+	r.planEffectiveEnv(ctx, req, resp)
+
+	if r.defaults == nil {
+		return
+	}
+
+	var memoryMB types.Int64
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("memory_mb"), &memoryMB)...)
+	if memoryMB.IsNull() && !r.defaults.MemoryMB.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("memory_mb"), r.defaults.MemoryMB)...)
+	}
+
+	var autostart types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("autostart"), &autostart)...)
+	if autostart.IsNull() && !r.defaults.Autostart.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("autostart"), r.defaults.Autostart)...)
+	}
+
+	var restartPolicy types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("restart_policy"), &restartPolicy)...)
+	if restartPolicy.IsNull() && !r.defaults.RestartPolicy.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("restart_policy"), r.defaults.RestartPolicy)...)
+	}
+}
+
+// planEffectiveEnv sets the planned effective_env and default_env attributes
+// of an instance about to be created.
+func (r *InstanceResource) planEffectiveEnv(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var envFileVars, env, sensitiveEnv, envWO types.Map
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("env_file_vars"), &envFileVars)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env"), &env)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("sensitive_env"), &sensitiveEnv)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env_wo"), &envWO)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	effective, diags := effectiveEnv(ctx, []types.Map{r.defaultEnv(), envFileVars, env}, sensitiveEnv, envWO)
+	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("effective_env"), effective)...)
+
+	defaults, diags := effectiveEnv(ctx, []types.Map{r.defaultEnv()}, envFileVars, env, sensitiveEnv, envWO)
+	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("default_env"), defaults)...)
+}
+
+// defaultEnv returns the default environment variables of instances.
+func (r *InstanceResource) defaultEnv() types.Map {
+	if r.defaults == nil {
+		return types.MapNull(types.StringType)
+	}
+	return r.defaults.Env
 }
 
 // Create implements resource.Resource.
 func (r *InstanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := startSpan(ctx, r.tracer, instanceResourceType+".Create",
+		spanAttrResourceType.String(instanceResourceType),
+	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
+	if r.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("create instance"))
+		return
+	}
+
 	var data InstanceResourceModel
 
 	// Read Terraform plan data into the model
@@ -269,8 +629,11 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 		data.MemoryMB = types.Int64Value(128)
 	}
 
+	// Short image references are qualified with the account's namespace. The
+	// configured reference is kept in the state, for the same reason as
+	// explained below.
 	in := instances.CreateRequest{
-		Image:    data.Image.ValueString(),
+		Image:    normalizeImage(data.Image.ValueString(), r.namespace),
 		MemoryMB: ptr(int(data.MemoryMB.ValueInt64())),
 		ServiceGroup: &instances.CreateRequestServiceGroup{
 			Services: make([]services.CreateRequestService, len(data.ServiceGroup.Services)),
@@ -278,6 +641,62 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 		Autostart: ptr(data.Autostart.ValueBool()),
 	}
 
+	// Neither configured nor defaulted by the provider.
+	if data.Autostart.IsUnknown() {
+		data.Autostart = types.BoolValue(false)
+	}
+
+	name, err := instanceName(data.Name, data.NamePrefix)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Generate Instance Name",
+			"The provider cannot generate a random suffix for the name of the instance: "+err.Error(),
+		)
+		return
+	}
+	in.Name = name
+
+	if data.RestartPolicy.IsUnknown() {
+		data.RestartPolicy = types.StringNull()
+	}
+	if !data.RestartPolicy.IsNull() {
+		in.RestartPolicy = ptr(instances.RestartPolicy(data.RestartPolicy.ValueString()))
+	}
+
+	// Write-only values are only available in the configuration. So is the
+	// env attribute when it is not configured, as it is then computed.
+	var env, envWO types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env"), &env)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env_wo"), &envWO)...)
+
+	if data.EnvFileVars.IsUnknown() {
+		data.EnvFileVars = types.MapNull(types.StringType)
+	}
+
+	// Default variables are overridden by the ones of the environment file,
+	// which are overridden by all others.
+	effective, d := effectiveEnv(ctx, []types.Map{r.defaultEnv(), data.EnvFileVars, env}, data.SensitiveEnv, envWO)
+	resp.Diagnostics.Append(d...)
+	if data.EffectiveEnv.IsUnknown() {
+		data.EffectiveEnv = effective
+	}
+	if data.DefaultEnv.IsUnknown() {
+		data.DefaultEnv, d = effectiveEnv(ctx, []types.Map{r.defaultEnv()}, data.EnvFileVars, env, data.SensitiveEnv, envWO)
+		resp.Diagnostics.Append(d...)
+	}
+
+	for _, m := range []types.Map{effective, data.SensitiveEnv, envWO} {
+		if m.IsNull() || m.IsUnknown() || len(m.Elements()) == 0 {
+			continue
+		}
+		vars := make(map[string]string, len(m.Elements()))
+		resp.Diagnostics.Append(m.ElementsAs(ctx, &vars, false)...)
+		if in.Env == nil {
+			in.Env = make(map[string]string, len(vars))
+		}
+		maps.Copy(in.Env, vars)
+	}
+
 	argVals := make([]types.String, 0, len(data.Args.Elements()))
 	resp.Diagnostics.Append(data.Args.ElementsAs(ctx, &argVals, false)...)
 	for _, v := range argVals {
@@ -309,75 +728,86 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
-	insRaw, err := r.client.Create(ctx, in)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to create instance, got error: %v", err),
-		)
+	data.Metro = types.StringValue(r.clients.Metro(data.Metro))
+	client := r.clients.Instances(data.Metro.ValueString())
+
+	ctx = tflog.SetField(ctx, logFieldMetro, data.Metro.ValueString())
+	span.SetAttributes(spanAttrMetro.String(data.Metro.ValueString()))
+
+	// Only instances which are actually created count against the limit.
+	if d := r.creates.Take(); d != nil {
+		resp.Diagnostics.Append(d)
 		return
 	}
-	ins := insRaw.Data.Entries[0]
+	insRaw, err := client.Create(ctx, in)
+	if err != nil {
+		r.creates.Return()
+	}
 
-	data.UUID = types.StringValue(ins.UUID)
-	data.Name = types.StringValue(ins.Name)
-	if ins.ServiceGroup != nil && len(ins.ServiceGroup.Domains) > 0 {
-		data.FQDN = types.StringValue(ins.ServiceGroup.Domains[0].FQDN)
+	auditRec := auditRecord{
+		Operation:    auditOpCreate,
+		ResourceType: instanceResourceType,
+		Metro:        data.Metro.ValueString(),
+		Request:      in,
 	}
-	data.PrivateIP = types.StringValue(ins.PrivateIP)
-	data.PrivateFQDN = types.StringValue(ins.PrivateFQDN)
+	if err == nil && len(insRaw.Data.Entries) > 0 {
+		auditRec.UUID = insRaw.Data.Entries[0].UUID
+	}
+	r.logAudit(ctx, &resp.Diagnostics, auditRec, err)
 
-	// Not all attributes are returned by CreateInstance
-	insRawFull, err := r.client.Get(ctx, data.UUID.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to get instance state, got error: %v", err),
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "create instance", err, withAttributePaths(apiFieldPaths))...)
 		return
 	}
-	insFull := insRawFull.Data.Entries[0]
-
-	var diags diag.Diagnostics
-
-	// NOTE(antoineco): although the Image attribute may be transformed by
-	// Unikraft Cloud (e.g. replace the tag with a digest), we must not update the
-	// value read from the schema, otherwise Terraform fails to apply with the
-	// following error:
-	//
-	//   Error: Provider produced inconsistent result after apply
-	//   When applying changes to unikraft-cloud_instance.xyz, provider produced an unexpected new value: .image:
-	//     was cty.StringVal("myimage:latest"), but now cty.StringVal("myimage@sha256:18a381f0062...").
-	//
-	data.State = types.StringValue(string(insFull.State))
-	data.CreatedAt = types.StringValue(insFull.CreatedAt)
-	data.MemoryMB = types.Int64Value(int64(insFull.MemoryMB))
-	data.BootTimeUS = types.Int64Value(int64(insFull.BootTimeUs))
+	if len(insRaw.Data.Entries) == 0 {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "create instance", errors.New("no instance in API response"))...)
+		return
+	}
+	ins := insRaw.Data.Entries[0]
 
-	data.Args, diags = types.ListValueFrom(ctx, types.StringType, insFull.Args)
-	resp.Diagnostics.Append(diags...)
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, ins.UUID)
+	span.SetAttributes(spanAttrInstanceUUID.String(ins.UUID))
 
-	data.Env, diags = types.MapValueFrom(ctx, types.StringType, insFull.Env)
-	resp.Diagnostics.Append(diags...)
+	data.UUID = types.StringValue(ins.UUID)
 
-	if data.ServiceGroup == nil {
-		data.ServiceGroup = &svcGrpModel{}
+	// Not all attributes are returned by CreateInstance
+	getter := r.clients.InstanceGetter(data.Metro.ValueString())
+	insFull, err := getter.Get(ctx, ins.UUID)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
+		return
 	}
 
-	if insFull.ServiceGroup != nil {
-		data.ServiceGroup.UUID = types.StringValue(insFull.ServiceGroup.UUID)
-		data.ServiceGroup.Name = types.StringValue(insFull.ServiceGroup.Name)
+	// The instance is started or not according to the autostart attribute,
+	// then brought to its desired state. A failure to do so does not prevent
+	// the instance from being saved, as tainted.
+	if r.reconcileState(ctx, &resp.Diagnostics, data.Metro.ValueString(), ins.UUID, data.DesiredState, insFull.State) {
+		if insFull, err = getter.Get(ctx, ins.UUID); err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
+			return
+		}
 	}
 
-	netwIfaces := make([]netwIfaceModel, len(insFull.NetworkInterfaces))
-	for i, net := range insFull.NetworkInterfaces {
-		netwIfaces[i].UUID = types.StringValue(net.UUID)
-		netwIfaces[i].Name = types.StringValue(insFull.Name)
-		netwIfaces[i].PrivateIP = types.StringValue(net.PrivateIP)
-		netwIfaces[i].MAC = types.StringValue(net.MAC)
+	// The planned environment, if known, must be preserved to be consistent
+	// with the plan. The actual environment also contains variables injected
+	// by the platform, and the variables of the other attributes which must
+	// not be copied to the env attribute.
+	if data.Env.IsUnknown() {
+		env := maps.Clone(insFull.Env)
+		for _, m := range []types.Map{data.EnvFileVars, data.SensitiveEnv, envWO} {
+			for k := range m.Elements() {
+				delete(env, k)
+			}
+		}
+		data.Env, d = types.MapValueFrom(ctx, types.StringType, env)
+		resp.Diagnostics.Append(d...)
 	}
-	data.NetworkInterfaces, diags = types.ListValueFrom(ctx, netwIfaceModelType, netwIfaces)
-	resp.Diagnostics.Append(diags...)
+
+	resp.Diagnostics.Append(setInstanceModel(ctx, &data, insFull, false)...)
+
+	// The env attribute also holds variables which were not configured, which
+	// must be told apart when it changes.
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, privateKeyEnvKeys, envKeysPrivateState(env))...)
 
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -385,6 +815,13 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 
 // Read implements resource.Resource.
 func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := startSpan(ctx, r.tracer, instanceResourceType+".Read",
+		spanAttrResourceType.String(instanceResourceType),
+	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
 	var data InstanceResourceModel
 
 	// Read Terraform prior state data into the model
@@ -393,17 +830,44 @@ func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
-	insRaw, err := r.client.Get(ctx, data.UUID.ValueString())
+	// The metro is not known yet after "terraform import" without an explicit
+	// metro in the import identifier.
+	data.Metro = types.StringValue(r.clients.Metro(data.Metro))
+
+	ctx = tflog.SetField(ctx, logFieldMetro, data.Metro.ValueString())
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, data.UUID.ValueString())
+	span.SetAttributes(
+		spanAttrMetro.String(data.Metro.ValueString()),
+		spanAttrInstanceUUID.String(data.UUID.ValueString()),
+	)
+
+	// Reads of concurrently refreshed instances are coalesced into batched
+	// requests.
+	ins, err := r.clients.InstanceGetter(data.Metro.ValueString()).Get(ctx, data.UUID.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to get instance state, got error: %v", err),
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
 		return
 	}
-	ins := insRaw.Data.Entries[0]
 
-	var diags diag.Diagnostics
+	// Only the image is unset after "terraform import".
+	imported := data.Image.IsNull()
+
+	resp.Diagnostics.Append(setInstanceModel(ctx, &data, ins, imported)...)
+
+	// An instance which was started or stopped outside of Terraform is
+	// reported as a difference with its desired state.
+	if !data.DesiredState.IsNull() && !satisfiesDesiredState(data.DesiredState.ValueString(), ins.State) {
+		data.DesiredState = types.StringValue(desiredStateOf(ins.State))
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// setInstanceModel populates the given model with the attributes of the given
+// instance. All attributes of imported instances are populated.
+func setInstanceModel(ctx context.Context, data *InstanceResourceModel, ins *instances.GetResponseItem, imported bool) diag.Diagnostics {
+	var diags, d diag.Diagnostics
 
 	// NOTE(antoineco): although the Image attribute may be transformed by
 	// Unikraft Cloud (e.g. replace the tag with a digest), we must not update the
@@ -416,7 +880,7 @@ func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 	//
 	// However, we must still ensure that the Image attribute is populated by
 	// "terraform import".
-	if data.Image.IsNull() {
+	if imported {
 		data.Image = types.StringValue(ins.Image)
 	}
 	data.Name = types.StringValue(ins.Name)
@@ -430,11 +894,25 @@ func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 	data.MemoryMB = types.Int64Value(int64(ins.MemoryMB))
 	data.BootTimeUS = types.Int64Value(int64(ins.BootTimeUs))
 
-	data.Args, diags = types.ListValueFrom(ctx, types.StringType, ins.Args)
-	resp.Diagnostics.Append(diags...)
+	data.Args, d = types.ListValueFrom(ctx, types.StringType, ins.Args)
+	diags.Append(d...)
 
-	data.Env, diags = types.MapValueFrom(ctx, types.StringType, ins.Env)
-	resp.Diagnostics.Append(diags...)
+	// Imported instances can not tell sensitive variables apart from others,
+	// so all their variables are refreshed as part of the env attribute.
+	env := ins.Env
+	if !imported && !data.Env.IsNull() {
+		env = knownEnv(data.Env, ins.Env)
+	}
+	data.Env, d = types.MapValueFrom(ctx, types.StringType, env)
+	diags.Append(d...)
+	if imported {
+		data.EffectiveEnv = data.Env
+	}
+
+	if !data.SensitiveEnv.IsNull() {
+		data.SensitiveEnv, d = types.MapValueFrom(ctx, types.StringType, knownEnv(data.SensitiveEnv, ins.Env))
+		diags.Append(d...)
+	}
 
 	if data.ServiceGroup == nil {
 		data.ServiceGroup = &svcGrpModel{}
@@ -452,24 +930,160 @@ func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 		netwIfaces[i].PrivateIP = types.StringValue(net.PrivateIP)
 		netwIfaces[i].MAC = types.StringValue(net.MAC)
 	}
-	data.NetworkInterfaces, diags = types.ListValueFrom(ctx, netwIfaceModelType, netwIfaces)
+	data.NetworkInterfaces, d = types.ListValueFrom(ctx, netwIfaceModelType, netwIfaces)
+	diags.Append(d...)
+
+	return diags
+}
+
+// planEnvFile loads the variables of the given environment file into the
+// planned env_file_vars attribute, so that changes to them are planned.
+// Changes to the variables of the file replace the instance. The env
+// attribute of imported instances holds all their variables, so the file is
+// only compared with it until the first update after the import.
+func (r *InstanceResource) planEnvFile(ctx context.Context, envFile types.String,
+	req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse,
+) {
+	if envFile.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("env_file_vars"), types.MapUnknown(types.StringType))...)
+		return
+	}
+
+	name := envFile.ValueString()
+	env, err := readDotenvFile(name)
+	if err != nil {
+		detail := "The environment file cannot be read: " + err.Error()
+		var perr *dotenvError
+		if errors.As(err, &perr) {
+			detail = fmt.Sprintf("The environment file cannot be parsed: %s:%d: %s", name, perr.Line, perr.Msg)
+		}
+		resp.Diagnostics.AddAttributeError(path.Root("env_file"), "Invalid Environment File", detail)
+		return
+	}
+
+	for _, attr := range []string{"sensitive_env", "env_wo"} {
+		var m types.Map
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(attr), &m)...)
+		for k := range m.Elements() {
+			if _, ok := env[k]; ok {
+				resp.Diagnostics.AddAttributeError(
+					path.Root(attr).AtMapKey(k),
+					"Duplicate Environment Variable",
+					fmt.Sprintf("The environment variable %q is already set by the environment file %s.", k, name),
+				)
+			}
+		}
+	}
+
+	planned, diags := types.MapValueFrom(ctx, types.StringType, env)
 	resp.Diagnostics.Append(diags...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("env_file_vars"), planned)...)
 
-	// Save updated data into Terraform state
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	var prior types.Map
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("env_file_vars"), &prior)...)
+
+	importedEnv := types.MapNull(types.StringType)
+	imported, diags := req.Private.GetKey(ctx, privateKeyImported)
+	resp.Diagnostics.Append(diags...)
+	if imported != nil {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("env"), &importedEnv)...)
+	}
+
+	if envFileRequiresReplace(planned, prior, importedEnv) {
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("env_file_vars"))
+	}
 }
 
 // Update implements resource.Resource.
 func (r *InstanceResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	resp.Diagnostics.AddError(
-		"Unsupported",
-		"This resource does not support updates. Configuration changes were expected to have triggered a replacement "+
-			"of the resource. Please report this issue to the provider developers.",
+	ctx, span := startSpan(ctx, r.tracer, instanceResourceType+".Update",
+		spanAttrResourceType.String(instanceResourceType),
 	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
+	if r.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("update instance"))
+		return
+	}
+
+	var data, plan InstanceResourceModel
+
+	// Read Terraform prior state and plan data into the models
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Changes to all other attributes trigger a replacement of the instance,
+	// except for changes to the environment which only configure variables
+	// the instance already has, and drop the ones that are not configured.
+	data.DesiredState = plan.DesiredState
+	if !plan.Env.IsUnknown() {
+		data.Env = plan.Env
+	}
+	data.SensitiveEnv = plan.SensitiveEnv
+	if !plan.EnvFileVars.IsUnknown() {
+		data.EnvFileVars = plan.EnvFileVars
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, privateKeyImported, nil)...)
+
+	var env types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("env"), &env)...)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, privateKeyEnvKeys, envKeysPrivateState(env))...)
+
+	metro := r.clients.Metro(data.Metro)
+	getter := r.clients.InstanceGetter(metro)
+
+	ctx = tflog.SetField(ctx, logFieldMetro, metro)
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, data.UUID.ValueString())
+	span.SetAttributes(
+		spanAttrMetro.String(metro),
+		spanAttrInstanceUUID.String(data.UUID.ValueString()),
+	)
+
+	ins, err := getter.Get(ctx, data.UUID.ValueString())
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
+		return
+	}
+
+	if r.reconcileState(ctx, &resp.Diagnostics, metro, data.UUID.ValueString(), data.DesiredState, ins.State) {
+		if ins, err = getter.Get(ctx, data.UUID.ValueString()); err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get instance state", err)...)
+			return
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(setInstanceModel(ctx, &data, ins, false)...)
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 // Delete implements resource.Resource.
 func (r *InstanceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := startSpan(ctx, r.tracer, instanceResourceType+".Delete",
+		spanAttrResourceType.String(instanceResourceType),
+	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
+	if r.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("delete instance"))
+		return
+	}
+
 	var data InstanceResourceModel
 
 	// Read Terraform prior state data into the model
@@ -478,19 +1092,127 @@ func (r *InstanceResource) Delete(ctx context.Context, req resource.DeleteReques
 		return
 	}
 
-	_, err := r.client.Delete(ctx, data.UUID.ValueString())
+	metro := r.clients.Metro(data.Metro)
+
+	ctx = tflog.SetField(ctx, logFieldMetro, metro)
+	ctx = tflog.SetField(ctx, logFieldInstanceUUID, data.UUID.ValueString())
+	span.SetAttributes(
+		spanAttrMetro.String(metro),
+		spanAttrInstanceUUID.String(data.UUID.ValueString()),
+	)
+
+	// Only instances which are actually deleted count against the limit.
+	if d := r.deletes.Take(); d != nil {
+		resp.Diagnostics.Append(d)
+		return
+	}
+	_, err := r.clients.Instances(metro).Delete(ctx, data.UUID.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to get delete instance, got error: %v", err),
-		)
+		r.deletes.Return()
+	}
+
+	r.logAudit(ctx, &resp.Diagnostics, auditRecord{
+		Operation:    auditOpDelete,
+		ResourceType: instanceResourceType,
+		Metro:        metro,
+		UUID:         data.UUID.ValueString(),
+	}, err)
+
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "delete instance", err)...)
 		return
 	}
 }
 
+// reconcileState starts or stops the given instance if its observed state does
+// not satisfy its desired state. It returns whether the instance was started or
+// stopped. Instances are not waited for, and may therefore be left in a
+// transitional state.
+func (r *InstanceResource) reconcileState(ctx context.Context, diags *diag.Diagnostics,
+	metro, uuid string, desired types.String, observed instances.State,
+) bool {
+	if desired.IsNull() || desired.IsUnknown() || satisfiesDesiredState(desired.ValueString(), observed) {
+		return false
+	}
+
+	client := r.clients.Instances(metro)
+
+	op := auditOpStart
+	var err error
+	if desired.ValueString() == desiredStateStopped {
+		op = auditOpStop
+		_, err = client.Stop(ctx, 0, false, uuid)
+	} else {
+		_, err = client.Start(ctx, 0, uuid)
+	}
+
+	r.logAudit(ctx, diags, auditRecord{
+		Operation:    op,
+		ResourceType: instanceResourceType,
+		Metro:        metro,
+		UUID:         uuid,
+	}, err)
+
+	if err != nil {
+		diags.Append(apiErrorDiagnostics(ctx, op+" instance", err)...)
+		return false
+	}
+
+	return true
+}
+
+// logAudit records a call to the API in the audit log. Failures to write the
+// audit log are reported as warnings, since the recorded call already
+// happened.
+func (r *InstanceResource) logAudit(ctx context.Context, diags *diag.Diagnostics, rec auditRecord, callErr error) {
+	// The HTTP status code of the response to the call is known to the API
+	// error recorder of the operation.
+	rec.StatusCode = recordedStatusCode(ctx)
+	var apiErr *apiError
+	if errors.As(callErr, &apiErr) {
+		rec.StatusCode = apiErr.StatusCode
+	}
+
+	if err := r.audit.Log(rec, callErr); err != nil {
+		diags.AddWarning(
+			"Audit Log Error",
+			fmt.Sprintf("Failed to record the %s operation in the audit log: %v", rec.Operation, err),
+		)
+	}
+}
+
 // ImportState implements resource.ResourceWithImportState.
+//
+// The import identifier is either the UUID of the instance, or a string in
+// the format "<metro>/<uuid>" for instances located outside of the provider's
+// default metro.
 func (r *InstanceResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("uuid"), req, resp)
+	metro, uuid, ok := strings.Cut(req.ID, "/")
+	if !ok {
+		resource.ImportStatePassthroughID(ctx, path.Root("uuid"), req, resp)
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, privateKeyImported, []byte("true"))...)
+		return
+	}
+
+	if metro == "" || uuid == "" {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected import identifier with format: <uuid> or <metro>/<uuid>. Got: %q", req.ID),
+		)
+		return
+	}
+
+	if err := r.clients.ValidateMetro(metro); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid Unikraft Cloud API Metro",
+			"The metro of the import identifier is invalid: "+err.Error(),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("metro"), metro)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("uuid"), uuid)...)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, privateKeyImported, []byte("true"))...)
 }
 
 func ptr[T comparable](v T) *T { return &v }
@@ -0,0 +1,42 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"sdk.kraft.cloud/instances"
+)
+
+// Accepted values of an instance's desired state.
+const (
+	desiredStateRunning = "running"
+	desiredStateStopped = "stopped"
+)
+
+// desiredStates are the accepted values of an instance's desired state.
+var desiredStates = []string{
+	desiredStateRunning,
+	desiredStateStopped,
+}
+
+// desiredStateOf returns the desired state which corresponds to the given
+// observed state of an instance. Transitional states correspond to the state
+// the instance transitions to. Instances on standby, i.e. instances with
+// scale-to-zero enabled which are idle, are started again on demand and are
+// therefore running. Unknown states are returned as is.
+func desiredStateOf(observed instances.State) string {
+	switch s := string(observed); s {
+	case "running", "starting", "standby":
+		return desiredStateRunning
+	case "stopped", "stopping", "draining":
+		return desiredStateStopped
+	default:
+		return s
+	}
+}
+
+// satisfiesDesiredState returns whether an instance in the given observed
+// state is in the given desired state.
+func satisfiesDesiredState(desired string, observed instances.State) bool {
+	return desiredStateOf(observed) == desired
+}
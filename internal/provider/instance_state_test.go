@@ -0,0 +1,56 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"sdk.kraft.cloud/instances"
+)
+
+func TestDesiredStateOf(t *testing.T) {
+	testCases := map[instances.State]string{
+		"running":  desiredStateRunning,
+		"starting": desiredStateRunning,
+		"standby":  desiredStateRunning,
+		"stopped":  desiredStateStopped,
+		"stopping": desiredStateStopped,
+		"draining": desiredStateStopped,
+		"unknown":  "unknown",
+	}
+
+	for observed, want := range testCases {
+		t.Run(string(observed), func(t *testing.T) {
+			if got := desiredStateOf(observed); got != want {
+				t.Errorf("desiredStateOf(%q) = %q; expected %q", observed, got, want)
+			}
+		})
+	}
+}
+
+func TestSatisfiesDesiredState(t *testing.T) {
+	testCases := []struct {
+		desired  string
+		observed instances.State
+		want     bool
+	}{
+		{desiredStateRunning, "running", true},
+		{desiredStateRunning, "starting", true},
+		{desiredStateRunning, "standby", true},
+		{desiredStateRunning, "stopped", false},
+		{desiredStateRunning, "stopping", false},
+		{desiredStateStopped, "stopped", true},
+		{desiredStateStopped, "draining", true},
+		{desiredStateStopped, "running", false},
+		{desiredStateStopped, "standby", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desired+"/"+string(tc.observed), func(t *testing.T) {
+			if got := satisfiesDesiredState(tc.desired, tc.observed); got != tc.want {
+				t.Errorf("satisfiesDesiredState(%q, %q) = %t; expected %t", tc.desired, tc.observed, got, tc.want)
+			}
+		})
+	}
+}
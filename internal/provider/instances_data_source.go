@@ -13,10 +13,11 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-
-	"sdk.kraft.cloud/instances"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewInstancesDataSource() datasource.DataSource {
@@ -25,15 +26,20 @@ func NewInstancesDataSource() datasource.DataSource {
 
 // InstancesDataSource defines the data source implementation.
 type InstancesDataSource struct {
-	client instances.InstancesService
+	clients *clientPool
+	tracer  trace.Tracer
 }
 
 // Ensure InstancesDataSource satisfies various datasource interfaces.
 var _ datasource.DataSource = &InstancesDataSource{}
 
+// instancesDataSourceType is the full type name of the instances data source.
+const instancesDataSourceType = "unikraft-cloud_instances"
+
 // InstancesDataSourceModel describes the data source data model.
 type InstancesDataSourceModel struct {
-	States types.Set `tfsdk:"states"`
+	Metro  types.String `tfsdk:"metro"`
+	States types.Set    `tfsdk:"states"`
 
 	UUIDs types.List `tfsdk:"uuids"`
 }
@@ -50,6 +56,11 @@ func (d *InstancesDataSource) Schema(ctx context.Context, req datasource.SchemaR
 		MarkdownDescription: "Provides UUIDs of existing Unikraft Cloud instances.",
 
 		Attributes: map[string]schema.Attribute{
+			"metro": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Metro in which instances are listed. Defaults to the metro configured in the provider.",
+			},
 			"states": schema.SetAttribute{
 				ElementType: types.StringType,
 				MarkdownDescription: "Filter instances based on their current " +
@@ -82,20 +93,28 @@ func (d *InstancesDataSource) Configure(ctx context.Context, req datasource.Conf
 		return
 	}
 
-	client, ok := req.ProviderData.(instances.InstancesService)
+	pdata, ok := req.ProviderData.(*providerData)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected instances.InstancesServices, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *provider.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	d.client = client
+	d.clients = pdata.clients
+	d.tracer = pdata.tracer
 }
 
 // Read implements datasource.DataSource.
 func (d *InstancesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := startSpan(ctx, d.tracer, "data."+instancesDataSourceType+".Read",
+		spanAttrDataSourceType.String(instancesDataSourceType),
+	)
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
+	ctx = withAPIErrorRecorder(ctx)
+
 	var data InstancesDataSourceModel
 
 	// Read Terraform configuration data into the model
@@ -104,46 +123,73 @@ func (d *InstancesDataSource) Read(ctx context.Context, req datasource.ReadReque
 		return
 	}
 
-	instances, err := d.client.List(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Client Error",
-			fmt.Sprintf("Failed to list instances, got error: %v", err),
+	data.Metro = types.StringValue(d.clients.Metro(data.Metro))
+
+	if err := d.clients.ValidateMetro(data.Metro.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("metro"),
+			"Invalid Unikraft Cloud API Metro",
+			"The configured metro is invalid: "+err.Error(),
 		)
 		return
 	}
+	client := d.clients.Instances(data.Metro.ValueString())
+
+	ctx = tflog.SetField(ctx, logFieldMetro, data.Metro.ValueString())
+	span.SetAttributes(spanAttrMetro.String(data.Metro.ValueString()))
+
+	instances, err := client.List(ctx)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "list instances", err)...)
+		return
+	}
 
 	// FIXME(antoineco): filtering not implemented in SDK.
-	// Implemented client side for the time being (expensive operation).
-	if len(data.States.Elements()) > 0 {
+	// Implemented client side for the time being, by getting the state of all
+	// listed instances in a single request.
+	if len(data.States.Elements()) > 0 && len(instances.Data.Entries) > 0 {
 		stateVals := make([]types.String, 0, len(data.States.Elements()))
 		resp.Diagnostics.Append(data.States.ElementsAs(ctx, &stateVals, false)...)
 		if resp.Diagnostics.HasError() {
 			return
 		}
 
-		filteredInstances := instances.Data.Entries[:0]
-
+		listedUUIDs := make([]string, 0, len(instances.Data.Entries))
 		for _, ins := range instances.Data.Entries {
-			insStat, err := d.client.Get(ctx, ins.UUID)
-			if err != nil {
-				resp.Diagnostics.AddError(
-					"Client Error",
-					fmt.Sprintf("Failed to get state of instance %s, got error: %v", ins.UUID, err),
-				)
-				return
+			listedUUIDs = append(listedUUIDs, ins.UUID)
+		}
+
+		insStats, err := client.Get(ctx, listedUUIDs...)
+		if err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostics(ctx, "get state of instances", err)...)
+			return
+		}
+
+		// Instances deleted since they were listed are reported as errors,
+		// and never match.
+		matching := make(map[string]struct{}, len(insStats.Data.Entries))
+		for _, ins := range insStats.Data.Entries {
+			if ins.Status == "error" {
+				continue
 			}
 
 			// the number of possible states is small enough that iterating
 			// them for every instance is reasonably cheap
 			for _, st := range stateVals {
-				if insStat.Status == st.ValueString() {
-					filteredInstances = append(filteredInstances, ins)
+				if string(ins.State) == st.ValueString() {
+					matching[ins.UUID] = struct{}{}
 					break
 				}
 			}
 		}
 
+		filteredInstances := instances.Data.Entries[:0]
+		for _, ins := range instances.Data.Entries {
+			if _, ok := matching[ins.UUID]; ok {
+				filteredInstances = append(filteredInstances, ins)
+			}
+		}
+
 		instances.Data.Entries = filteredInstances
 	}
 
@@ -0,0 +1,90 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultKraftkitProfile is the entry of the kraftkit configuration file's
+// "auth" section used by the kraft CLI to store Unikraft Cloud credentials.
+const defaultKraftkitProfile = "index.unikraft.io"
+
+// kraftkitConfig describes the subset of the kraftkit configuration file
+// (usually ~/.config/kraftkit/config.yaml) which is relevant to the provider.
+type kraftkitConfig struct {
+	Auth map[string]kraftkitAuth `yaml:"auth"`
+}
+
+// kraftkitAuth describes an entry of the "auth" section of the kraftkit
+// configuration file. Entries do not hold a metro, which is therefore only
+// read from the provider configuration or from the environment.
+type kraftkitAuth struct {
+	User  string `yaml:"user"`
+	Token string `yaml:"token"`
+}
+
+// apiToken returns the Unikraft Cloud API token described by the entry.
+//
+// The kraft CLI stores the user name and the secret separately, whereas the
+// API expects both to be encoded together. A token stored without a user is
+// assumed to be already encoded.
+func (a kraftkitAuth) apiToken() string {
+	if a.Token == "" || a.User == "" {
+		return a.Token
+	}
+	return base64.StdEncoding.EncodeToString([]byte("robot$" + a.User + ".users.kraftcloud:" + a.Token))
+}
+
+// defaultKraftkitConfigPath returns the location of the kraftkit configuration
+// file as used by the kraft CLI.
+func defaultKraftkitConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "kraftkit", "config.yaml")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "kraftkit", "config.yaml")
+}
+
+// readKraftkitAuth reads the credentials stored under the given profile in the
+// kraftkit configuration file at path.
+//
+// A missing file is not an error when path was not explicitly requested by the
+// user (explicit=false), in which case a zero kraftkitAuth is returned.
+func readKraftkitAuth(path, profile string, explicit bool) (kraftkitAuth, error) {
+	if path == "" {
+		return kraftkitAuth{}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return kraftkitAuth{}, nil
+		}
+		return kraftkitAuth{}, fmt.Errorf("reading kraftkit configuration file: %w", err)
+	}
+
+	var cfg kraftkitConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return kraftkitAuth{}, fmt.Errorf("parsing kraftkit configuration file %s: %w", path, err)
+	}
+
+	auth, ok := cfg.Auth[profile]
+	if !ok && profile != defaultKraftkitProfile {
+		return kraftkitAuth{}, fmt.Errorf("profile %q not found in kraftkit configuration file %s", profile, path)
+	}
+
+	return auth, nil
+}
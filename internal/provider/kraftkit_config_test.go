@@ -0,0 +1,114 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadKraftkitAuth(t *testing.T) {
+	fixture := filepath.Join("testdata", "kraftkit", "config.yaml")
+
+	testCases := map[string]struct {
+		path     string
+		profile  string
+		explicit bool
+		want     kraftkitAuth
+		wantErr  bool
+	}{
+		"default profile": {
+			path:    fixture,
+			profile: defaultKraftkitProfile,
+			want:    kraftkitAuth{User: "myuser", Token: "kR4f7EXAMPLEKEY"},
+		},
+		"other profile": {
+			path:     fixture,
+			profile:  "staging",
+			explicit: true,
+			want:     kraftkitAuth{User: "other", Token: "st4g1ngEXAMPLEKEY"},
+		},
+		"encoded token": {
+			path:     fixture,
+			profile:  "encoded",
+			explicit: true,
+			want:     kraftkitAuth{Token: "cm9ib3QkZW5jb2RlZC51c2Vycy5rcmFmdGNsb3VkOnNlY3JldA=="},
+		},
+		"missing profile": {
+			path:     fixture,
+			profile:  "missing",
+			explicit: true,
+			wantErr:  true,
+		},
+		"missing default profile": {
+			path:    filepath.Join("testdata", "kraftkit", "empty.yaml"),
+			profile: defaultKraftkitProfile,
+		},
+		"missing file": {
+			path:    filepath.Join("testdata", "kraftkit", "missing.yaml"),
+			profile: defaultKraftkitProfile,
+		},
+		"missing explicit file": {
+			path:     filepath.Join("testdata", "kraftkit", "missing.yaml"),
+			profile:  defaultKraftkitProfile,
+			explicit: true,
+			wantErr:  true,
+		},
+		"invalid file": {
+			path:    filepath.Join("testdata", "kraftkit", "invalid.yaml"),
+			profile: defaultKraftkitProfile,
+			wantErr: true,
+		},
+		"no path": {
+			profile: defaultKraftkitProfile,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := readKraftkitAuth(tc.path, tc.profile, tc.explicit)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("readKraftkitAuth(%q, %q, %t) error = %v; expected error: %t",
+					tc.path, tc.profile, tc.explicit, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("readKraftkitAuth(%q, %q, %t) = %+v; expected %+v",
+					tc.path, tc.profile, tc.explicit, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKraftkitAuthAPIToken(t *testing.T) {
+	testCases := map[string]struct {
+		auth kraftkitAuth
+		want string
+	}{
+		"user and token": {
+			auth: kraftkitAuth{User: "myuser", Token: "kR4f7EXAMPLEKEY"},
+			want: "robot$myuser.users.kraftcloud:kR4f7EXAMPLEKEY",
+		},
+		"encoded token": {
+			auth: kraftkitAuth{Token: "cm9ib3QkZW5jb2RlZC51c2Vycy5rcmFmdGNsb3VkOnNlY3JldA=="},
+			want: "robot$encoded.users.kraftcloud:secret",
+		},
+		"no token": {
+			auth: kraftkitAuth{User: "myuser"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.auth.apiToken()
+			decoded, err := base64.StdEncoding.DecodeString(got)
+			if err != nil {
+				t.Fatalf("apiToken() = %q; expected base64: %v", got, err)
+			}
+			if string(decoded) != tc.want {
+				t.Errorf("apiToken() = %q, decoded %q; expected %q", got, decoded, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// limitTransport is a http.RoundTripper which limits the number of API
+// requests in flight at any time. It is shared by all API clients of the
+// provider, regardless of their metro.
+type limitTransport struct {
+	// sem holds one element per request in flight.
+	sem  chan struct{}
+	next http.RoundTripper
+}
+
+var _ http.RoundTripper = (*limitTransport)(nil)
+
+// newLimitTransport returns a limitTransport which allows up to n concurrent
+// requests.
+func newLimitTransport(n int, next http.RoundTripper) *limitTransport {
+	return &limitTransport{
+		sem:  make(chan struct{}, n),
+		next: next,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	start := time.Now()
+
+	select {
+	case t.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-t.sem }()
+
+	if wait := time.Since(start); wait >= time.Millisecond {
+		tflog.Debug(ctx, "Waited for a free slot before sending Unikraft Cloud API request", map[string]any{
+			"http_method":  req.Method,
+			"http_url":     req.URL.Redacted(),
+			"wait_ms":      wait.Milliseconds(),
+			"max_requests": cap(t.sem),
+		})
+	}
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// maxLoggedBodySize is the maximum number of bytes of an HTTP request or
+// response body included in log entries.
+const maxLoggedBodySize = 16 << 10
+
+// maxRedactedBodySize is the maximum size of an HTTP request or response body
+// which is decoded for redaction. Larger bodies are not logged.
+const maxRedactedBodySize = 4 << 20
+
+// redactedValue replaces sensitive values in log entries.
+const redactedValue = "***"
+
+// Keys of log fields which identify the Unikraft Cloud object concerned by an
+// API request. Set by resources and data sources on the request context.
+const (
+	logFieldMetro        = "metro"
+	logFieldInstanceUUID = "instance_uuid"
+)
+
+// sensitiveJSONKeys are keys of JSON objects exchanged with the API whose
+// values are redacted from log entries. All values of JSON objects under those
+// keys are redacted as well.
+var sensitiveJSONKeys = map[string]struct{}{
+	"env":      {},
+	"token":    {},
+	"password": {},
+	"secret":   {},
+}
+
+// loggingTransport is a http.RoundTripper which emits a debug log entry for
+// every API request and its response. Credentials and sensitive values are
+// redacted from those entries.
+type loggingTransport struct {
+	// secrets are strings masked in all log entries, such as the API token.
+	secrets []string
+	next    http.RoundTripper
+}
+
+var _ http.RoundTripper = (*loggingTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := tflog.MaskAllFieldValuesStrings(req.Context(), t.secrets...)
+	ctx = tflog.MaskMessageStrings(ctx, t.secrets...)
+
+	fields := map[string]any{
+		"http_method": req.Method,
+		"http_url":    req.URL.Redacted(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			fields["http_request_body"] = redactBody(body)
+		}
+	}
+	tflog.Debug(ctx, "Sending Unikraft Cloud API request", fields)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	fields["http_duration_ms"] = time.Since(start).Milliseconds()
+	delete(fields, "http_request_body")
+
+	if err != nil {
+		fields["error"] = err.Error()
+		tflog.Debug(ctx, "Unikraft Cloud API request failed", fields)
+		return resp, err
+	}
+
+	fields["http_status_code"] = resp.StatusCode
+
+	b, rerr := readResponseBody(resp)
+	if rerr != nil {
+		fields["error"] = rerr.Error()
+	} else {
+		fields["http_response_body"] = redactBody(bytes.NewReader(b))
+	}
+
+	tflog.Debug(ctx, "Received Unikraft Cloud API response", fields)
+
+	return resp, rerr
+}
+
+// redactBody returns a loggable representation of the given HTTP body, with
+// sensitive values redacted, truncated to maxLoggedBodySize. JSON bodies are
+// redacted before being truncated. Bodies which can not be redacted are
+// replaced by a placeholder, unless they are short non-JSON bodies such as
+// error pages, which are returned as is.
+func redactBody(r io.Reader) string {
+	if rc, ok := r.(io.Closer); ok {
+		defer rc.Close()
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, maxRedactedBodySize+1))
+	if err != nil || len(b) == 0 {
+		return ""
+	}
+
+	if len(b) > maxRedactedBodySize {
+		n, _ := io.Copy(io.Discard, r)
+		return notLoggedBody(int64(len(b)) + n)
+	}
+
+	var v any
+	if json.Unmarshal(b, &v) == nil {
+		rb, err := json.Marshal(redactJSON(v, false))
+		if err != nil {
+			return notLoggedBody(int64(len(b)))
+		}
+		return truncateBody(string(rb))
+	}
+
+	if len(b) > maxLoggedBodySize || looksLikeJSON(b) {
+		return notLoggedBody(int64(len(b)))
+	}
+	return string(b)
+}
+
+// truncateBody truncates the given loggable body to maxLoggedBodySize.
+func truncateBody(s string) string {
+	if len(s) > maxLoggedBodySize {
+		return s[:maxLoggedBodySize] + "...(truncated)"
+	}
+	return s
+}
+
+// notLoggedBody returns the placeholder logged instead of a body of n bytes
+// which can not be redacted.
+func notLoggedBody(n int64) string {
+	return fmt.Sprintf("<%d bytes, not logged>", n)
+}
+
+// looksLikeJSON returns whether b seems to be a JSON object or array, e.g. a
+// malformed one, which may hold sensitive values.
+func looksLikeJSON(b []byte) bool {
+	b = bytes.TrimSpace(b)
+	return len(b) > 0 && (b[0] == '{' || b[0] == '[')
+}
+
+// redactJSON replaces the values of sensitive keys inside the decoded JSON
+// value v. If redact is true, all scalar values within v are replaced.
+func redactJSON(v any, redact bool) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, e := range vv {
+			_, sensitive := sensitiveJSONKeys[strings.ToLower(k)]
+			vv[k] = redactJSON(e, redact || sensitive)
+		}
+		return vv
+	case []any:
+		for i, e := range vv {
+			vv[i] = redactJSON(e, redact)
+		}
+		return vv
+	case nil:
+		return nil
+	default:
+		if redact {
+			return redactedValue
+		}
+		return vv
+	}
+}
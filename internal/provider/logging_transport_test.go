@@ -0,0 +1,138 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	testCases := map[string]struct {
+		in     string
+		redact bool
+		want   string
+	}{
+		"no sensitive keys": {
+			in:   `{"name":"nginx","memory_mb":128,"args":["-c","x"]}`,
+			want: `{"args":["-c","x"],"memory_mb":128,"name":"nginx"}`,
+		},
+		"sensitive scalar": {
+			in:   `{"token":"abc","name":"nginx"}`,
+			want: `{"name":"nginx","token":"***"}`,
+		},
+		"sensitive keys are case insensitive": {
+			in:   `{"Password":"abc","SECRET":"def"}`,
+			want: `{"Password":"***","SECRET":"***"}`,
+		},
+		"sensitive object": {
+			in:   `{"env":{"A":"1","B":"2"},"name":"nginx"}`,
+			want: `{"env":{"A":"***","B":"***"},"name":"nginx"}`,
+		},
+		"nested sensitive object": {
+			in:   `{"data":{"instances":[{"uuid":"u1","env":{"A":"1"}}]}}`,
+			want: `{"data":{"instances":[{"env":{"A":"***"},"uuid":"u1"}]}}`,
+		},
+		"null values are kept": {
+			in:   `{"env":null,"token":null}`,
+			want: `{"env":null,"token":null}`,
+		},
+		"redact all": {
+			in:     `{"a":1,"b":[true,"x"],"c":{"d":"e"}}`,
+			redact: true,
+			want:   `{"a":"***","b":["***","***"],"c":{"d":"***"}}`,
+		},
+		"scalar": {
+			in:   `"value"`,
+			want: `"value"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tc.in), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(redactJSON(v, tc.redact))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	large := strings.Repeat("a", maxLoggedBodySize+10)
+
+	// largeJSON is a JSON body larger than maxLoggedBodySize, whose sensitive
+	// values appear past the truncation point.
+	largeJSON := `{"data":{"instances":[` +
+		strings.Repeat(`{"name":"`+strings.Repeat("n", 100)+`"},`, maxLoggedBodySize/100) +
+		`{"env":{"API_KEY":"secret"}}]}}`
+
+	testCases := map[string]struct {
+		body string
+		want string
+	}{
+		"empty": {
+			body: "",
+			want: "",
+		},
+		"JSON": {
+			body: `{"image":"nginx:latest","env":{"API_KEY":"secret"}}`,
+			want: `{"env":{"API_KEY":"***"},"image":"nginx:latest"}`,
+		},
+		"not JSON": {
+			body: "Bad Gateway",
+			want: "Bad Gateway",
+		},
+		"large not JSON": {
+			body: large,
+			want: fmt.Sprintf("<%d bytes, not logged>", len(large)),
+		},
+		"malformed JSON": {
+			body: `{"env":{"API_KEY":"secret"`,
+			want: "<26 bytes, not logged>",
+		},
+		"too large to redact": {
+			body: `{"a":"` + strings.Repeat("a", maxRedactedBodySize) + `"}`,
+			want: fmt.Sprintf("<%d bytes, not logged>", maxRedactedBodySize+8),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := redactBody(strings.NewReader(tc.body)); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("large JSON", func(t *testing.T) {
+		got := redactBody(strings.NewReader(largeJSON))
+
+		if strings.Contains(got, "secret") {
+			t.Errorf("expected sensitive values to be redacted, got %q", got)
+		}
+		if !strings.HasSuffix(got, "...(truncated)") || len(got) != maxLoggedBodySize+len("...(truncated)") {
+			t.Errorf("expected the redacted body to be truncated, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("large JSON with sensitive values before the truncation point", func(t *testing.T) {
+		body := `{"env":{"API_KEY":"secret"},"payload":"` + strings.Repeat("a", maxLoggedBodySize) + `"}`
+		got := redactBody(strings.NewReader(body))
+
+		if strings.Contains(got, "secret") || !strings.Contains(got, `"env":{"API_KEY":"***"}`) {
+			t.Errorf("expected sensitive values to be redacted, got %q", got[:100])
+		}
+	})
+}
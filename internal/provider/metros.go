@@ -0,0 +1,121 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// builtinMetros are the metros offered by Unikraft Cloud, indexed by name.
+var builtinMetros = map[string]string{
+	"dal0": "Dallas, United States",
+	"fra0": "Frankfurt, Germany",
+	"sin0": "Singapore",
+	"was1": "Washington, United States",
+}
+
+// maxMetroSuggestionDistance is the maximum edit distance between an unknown
+// metro and a known metro for the latter to be suggested.
+const maxMetroSuggestionDistance = 2
+
+// metroCatalog validates the metros referenced by the provider's
+// configuration, resources and data sources.
+//
+// A nil *metroCatalog accepts all metros.
+type metroCatalog struct {
+	// custom are metros accepted in addition to the built-in metros. They may
+	// be full URLs of API endpoints.
+	custom map[string]struct{}
+}
+
+// newMetroCatalog returns a metroCatalog which accepts the built-in metros as
+// well as the given custom metros.
+func newMetroCatalog(custom ...string) *metroCatalog {
+	c := &metroCatalog{custom: make(map[string]struct{}, len(custom))}
+	for _, m := range custom {
+		c.custom[m] = struct{}{}
+	}
+	return c
+}
+
+// Validate returns an error if the given metro is unknown.
+func (c *metroCatalog) Validate(metro string) error {
+	if c == nil {
+		return nil
+	}
+	if _, ok := builtinMetros[metro]; ok {
+		return nil
+	}
+	if _, ok := c.custom[metro]; ok {
+		return nil
+	}
+
+	if strings.Contains(metro, "://") {
+		return fmt.Errorf("metro %q is a URL, which is only accepted when listed in the provider's custom_metros "+
+			"attribute. To send all API requests to a custom endpoint, set the provider's endpoint attribute instead", metro)
+	}
+
+	known := c.names()
+
+	msg := fmt.Sprintf("unknown metro %q.", metro)
+	if s := suggestMetro(metro, known); s != "" {
+		msg = fmt.Sprintf("unknown metro %q, did you mean %q?", metro, s)
+	}
+	return fmt.Errorf("%s Valid metros are: %s. Metros which are not offered by Unikraft Cloud publicly can "+
+		"be accepted by listing them in the provider's custom_metros attribute", msg, strings.Join(known, ", "))
+}
+
+// names returns the sorted names of all accepted metros which are not URLs.
+func (c *metroCatalog) names() []string {
+	names := make([]string, 0, len(builtinMetros)+len(c.custom))
+	for m := range builtinMetros {
+		names = append(names, m)
+	}
+	for m := range c.custom {
+		if !strings.Contains(m, "://") {
+			names = append(names, m)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggestMetro returns the metro among known which is the nearest to the given
+// metro, or an empty string if none is near enough.
+func suggestMetro(metro string, known []string) string {
+	best, bestDist := "", maxMetroSuggestionDistance+1
+	for _, k := range known {
+		if d := levenshtein(strings.ToLower(metro), k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between the strings a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetroCatalogValidate(t *testing.T) {
+	testCases := map[string]struct {
+		catalog *metroCatalog
+		metro   string
+		// wantErr holds substrings of the expected error, if any.
+		wantErr []string
+	}{
+		"built-in metro": {
+			catalog: newMetroCatalog(),
+			metro:   "fra0",
+		},
+		"custom metro": {
+			catalog: newMetroCatalog("lab0"),
+			metro:   "lab0",
+		},
+		"custom URL": {
+			catalog: newMetroCatalog("https://api.lab.example.com"),
+			metro:   "https://api.lab.example.com",
+		},
+		"nil catalog": {
+			metro: "nowhere",
+		},
+		"unknown metro with suggestion": {
+			catalog: newMetroCatalog(),
+			metro:   "fra1",
+			wantErr: []string{`unknown metro "fra1", did you mean "fra0"?`, "dal0, fra0, sin0, was1"},
+		},
+		"unknown metro in upper case": {
+			catalog: newMetroCatalog(),
+			metro:   "SIN0",
+			wantErr: []string{`did you mean "sin0"?`},
+		},
+		"unknown metro without suggestion": {
+			catalog: newMetroCatalog(),
+			metro:   "tokyo",
+			wantErr: []string{`unknown metro "tokyo".`, "custom_metros"},
+		},
+		"custom metros are listed": {
+			catalog: newMetroCatalog("lab0", "https://api.lab.example.com"),
+			metro:   "xyz",
+			wantErr: []string{"dal0, fra0, lab0, sin0, was1."},
+		},
+		"unknown URL": {
+			catalog: newMetroCatalog(),
+			metro:   "https://api.lab.example.com",
+			wantErr: []string{"is a URL", "endpoint attribute"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.catalog.Validate(tc.metro)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, s := range tc.wantErr {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("expected the error to contain %q, got: %v", s, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestMetro(t *testing.T) {
+	known := []string{"dal0", "fra0", "sin0", "was1"}
+
+	testCases := map[string]struct {
+		metro string
+		want  string
+	}{
+		"one substitution": {
+			metro: "fra1",
+			want:  "fra0",
+		},
+		"missing character": {
+			metro: "was",
+			want:  "was1",
+		},
+		"upper case": {
+			metro: "DAL0",
+			want:  "dal0",
+		},
+		"too far": {
+			metro: "frankfurt",
+		},
+		"empty": {
+			metro: "",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := suggestMetro(tc.metro, known); got != tc.want {
+				t.Errorf("suggestMetro(%q) = %q; expected %q", tc.metro, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"fra0", "fra0", 0},
+		{"fra0", "fra1", 1},
+		{"was", "was1", 1},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"zürich", "zurich", 1},
+	}
+
+	for _, tc := range testCases {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d; expected %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -8,6 +8,15 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
+// instanceDefaultsModel describes the data model for the provider's default
+// instance settings.
+type instanceDefaultsModel struct {
+	MemoryMB      types.Int64  `tfsdk:"memory_mb"`
+	Autostart     types.Bool   `tfsdk:"autostart"`
+	Env           types.Map    `tfsdk:"env"`
+	RestartPolicy types.String `tfsdk:"restart_policy"`
+}
+
 // svcGrpModel describes the data model for an instance's service group.
 type svcGrpModel struct {
 	UUID     types.String `tfsdk:"uuid"`
@@ -0,0 +1,75 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// operationLimit caps the number of operations of a given kind, such as the
+// deletion of instances, performed during the lifetime of the provider
+// process, i.e. during a single Terraform operation. It guards against
+// unexpectedly large changes, e.g. caused by a mistake in the configuration.
+//
+// A nil *operationLimit is unlimited.
+type operationLimit struct {
+	// op describes the limited operation, e.g. "delete instance".
+	op string
+	// attr is the provider attribute which sets the limit.
+	attr string
+
+	mu    sync.Mutex
+	max   int
+	count int
+}
+
+// SetMax sets the maximum number of operations. Operations which were already
+// performed still count against the new maximum.
+func (l *operationLimit) SetMax(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.max = n
+}
+
+// Take accounts for one more operation, right before it is performed. It
+// returns an error diagnostic if the operation would exceed the limit.
+func (l *operationLimit) Take() diag.Diagnostic {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.count >= l.max {
+		return diag.NewErrorDiagnostic(
+			"Operation Limit Exceeded",
+			fmt.Sprintf("Refusing to %s because this would exceed the maximum of %d such operations per run, "+
+				"set by the provider's %s attribute. This limit guards against unexpectedly large changes. "+
+				"Review the plan, then raise the limit or apply the changes in several runs.", l.op, l.max, l.attr),
+		)
+	}
+	l.count++
+
+	return nil
+}
+
+// Return gives back an operation accounted for by Take which failed, and
+// therefore does not count against the limit.
+func (l *operationLimit) Return() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count > 0 {
+		l.count--
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOperationLimitTake(t *testing.T) {
+	testCases := map[string]struct {
+		max   int
+		takes int
+		// wantAllowed is the number of operations expected to be allowed.
+		wantAllowed int
+	}{
+		"unlimited": {
+			max:         0,
+			takes:       100,
+			wantAllowed: 100,
+		},
+		"below the limit": {
+			max:         5,
+			takes:       3,
+			wantAllowed: 3,
+		},
+		"at the limit": {
+			max:         5,
+			takes:       5,
+			wantAllowed: 5,
+		},
+		"above the limit": {
+			max:         5,
+			takes:       50,
+			wantAllowed: 5,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			l := &operationLimit{op: "delete instance", attr: "max_deletes_per_apply"}
+			l.SetMax(tc.max)
+
+			var allowed atomic.Int64
+			var wg sync.WaitGroup
+			for range tc.takes {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if d := l.Take(); d == nil {
+						allowed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := int(allowed.Load()); got != tc.wantAllowed {
+				t.Errorf("expected %d operations to be allowed, got %d", tc.wantAllowed, got)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var l *operationLimit
+		if d := l.Take(); d != nil {
+			t.Errorf("expected no limit, got %q", d.Summary())
+		}
+	})
+
+	t.Run("lowered maximum", func(t *testing.T) {
+		l := &operationLimit{op: "create instance", attr: "max_creates_per_apply"}
+		for range 3 {
+			if d := l.Take(); d != nil {
+				t.Fatalf("unexpected diagnostic: %s", d.Summary())
+			}
+		}
+
+		// Operations already performed count against the new maximum.
+		l.SetMax(3)
+		if d := l.Take(); d == nil {
+			t.Error("expected the limit to be exceeded")
+		}
+	})
+}
+
+func TestOperationLimitReturn(t *testing.T) {
+	l := &operationLimit{op: "create instance", attr: "max_creates_per_apply"}
+	l.SetMax(2)
+
+	// A failed create does not count against the limit.
+	if d := l.Take(); d != nil {
+		t.Fatalf("unexpected diagnostic: %s", d.Summary())
+	}
+	l.Return()
+
+	for i := range 2 {
+		if d := l.Take(); d != nil {
+			t.Fatalf("operation %d: unexpected diagnostic: %s", i, d.Summary())
+		}
+	}
+	if d := l.Take(); d == nil {
+		t.Error("expected the limit to be exceeded")
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var l *operationLimit
+		l.Return()
+	})
+
+	t.Run("nothing taken", func(t *testing.T) {
+		l := &operationLimit{op: "delete instance", attr: "max_deletes_per_apply"}
+		l.SetMax(1)
+
+		// Returning more than was taken does not raise the limit.
+		l.Return()
+		for i, want := range []bool{true, false} {
+			if d := l.Take(); (d == nil) != want {
+				t.Errorf("operation %d: expected allowed = %t", i, want)
+			}
+		}
+	})
+}
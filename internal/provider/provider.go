@@ -5,14 +5,26 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"go.opentelemetry.io/otel/trace"
 
 	unikraftcloud "sdk.kraft.cloud"
 	"sdk.kraft.cloud/client"
@@ -20,9 +32,24 @@ import (
 
 func New(version string) func() provider.Provider {
 	return func() provider.Provider {
-		return &UnikraftCloudProvider{
-			version: version,
-		}
+		return newProvider(version, false)
+	}
+}
+
+// NewEmulated returns a provider which serves all API requests with an
+// embedded emulator of Unikraft Cloud, regardless of its configuration.
+func NewEmulated(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return newProvider(version, true)
+	}
+}
+
+func newProvider(version string, emulated bool) *UnikraftCloudProvider {
+	return &UnikraftCloudProvider{
+		version:  version,
+		emulated: emulated,
+		creates:  operationLimit{op: "create instance", attr: "max_creates_per_apply"},
+		deletes:  operationLimit{op: "delete instance", attr: "max_deletes_per_apply"},
 	}
 }
 
@@ -32,6 +59,23 @@ type UnikraftCloudProvider struct {
 	// provider is built and ran locally, and "test" when running acceptance
 	// testing.
 	version string
+
+	// tokens caches the API tokens obtained from the configured token
+	// command, if any.
+	tokens tokenCommandCache
+
+	// creates and deletes limit the number of instances created and deleted
+	// by the provider process, across all resources.
+	creates operationLimit
+	deletes operationLimit
+
+	// emulated forces the use of the emulator.
+	emulated bool
+	// emulators holds the emulators used by the provider process, indexed by
+	// the path of their state file. Emulators of other provider processes
+	// which use the same state file are kept consistent by locking that file.
+	emulatorsMu sync.Mutex
+	emulators   map[string]*emulator
 }
 
 // Ensure UnikraftCloudProvider satisfies various provider interfaces.
@@ -39,8 +83,55 @@ var _ provider.Provider = &UnikraftCloudProvider{}
 
 // UnikraftCloudModel describes the provider data model.
 type UnikraftCloudModel struct {
-	Metro types.String `tfsdk:"metro"`
-	Token types.String `tfsdk:"token"`
+	Metro        types.String `tfsdk:"metro"`
+	CustomMetros types.Set    `tfsdk:"custom_metros"`
+	Token        types.String `tfsdk:"token"`
+	TokenCommand types.List   `tfsdk:"token_command"`
+	ConfigPath   types.String `tfsdk:"config_path"`
+	Profile      types.String `tfsdk:"profile"`
+
+	Endpoint           types.String `tfsdk:"endpoint"`
+	CACertPEM          types.String `tfsdk:"ca_cert_pem"`
+	CACertFile         types.String `tfsdk:"ca_cert_file"`
+	InsecureSkipVerify types.Bool   `tfsdk:"insecure_skip_verify"`
+
+	MaxRetries   types.Int64  `tfsdk:"max_retries"`
+	RetryMaxWait types.String `tfsdk:"retry_max_wait"`
+
+	MaxConcurrentRequests types.Int64 `tfsdk:"max_concurrent_requests"`
+
+	Defaults *instanceDefaultsModel `tfsdk:"defaults"`
+
+	ReadOnly types.Bool `tfsdk:"read_only"`
+
+	AuditLogPath types.String `tfsdk:"audit_log_path"`
+
+	Tracing *tracingModel `tfsdk:"tracing"`
+
+	SkipCredentialsValidation types.Bool `tfsdk:"skip_credentials_validation"`
+
+	Namespace            types.String `tfsdk:"namespace"`
+	AllowedImagePatterns types.List   `tfsdk:"allowed_image_patterns"`
+
+	MaxCreatesPerApply types.Int64 `tfsdk:"max_creates_per_apply"`
+	MaxDeletesPerApply types.Int64 `tfsdk:"max_deletes_per_apply"`
+
+	Emulator          types.Bool   `tfsdk:"emulator"`
+	EmulatorStatePath types.String `tfsdk:"emulator_state_path"`
+}
+
+// providerData is the data shared by the provider with its resources and data
+// sources.
+type providerData struct {
+	clients   *clientPool
+	defaults  *instanceDefaultsModel
+	readOnly  bool
+	audit     *auditLogger
+	tracer    trace.Tracer
+	images    *imagePolicy
+	namespace string
+	creates   *operationLimit
+	deletes   *operationLimit
 }
 
 // Metadata implements provider.Provider.
@@ -57,20 +148,227 @@ func (p *UnikraftCloudProvider) Schema(ctx context.Context, req provider.SchemaR
 
 		Attributes: map[string]schema.Attribute{
 			"metro": schema.StringAttribute{
-				MarkdownDescription: "API metro",
+				MarkdownDescription: "Default API metro. Can be overridden by individual resources and data sources.",
 				Optional:            true,
 			},
+			"custom_metros": schema.SetAttribute{
+				ElementType: types.StringType,
+				MarkdownDescription: "Metros accepted in addition to the metros offered by Unikraft Cloud, such as " +
+					"private metros. Values may be full URLs of API endpoints, which are otherwise rejected. Metros " +
+					"are not validated when `endpoint` is set.",
+				Optional: true,
+			},
 			"token": schema.StringAttribute{
 				MarkdownDescription: "API token",
 				Optional:            true,
 				Sensitive:           true,
 			},
+			"token_command": schema.ListAttribute{
+				ElementType: types.StringType,
+				MarkdownDescription: "Command, followed by its arguments, which writes the API token to its standard " +
+					"output. Executed once during the lifetime of the provider. Conflicts with `token`.",
+				Optional: true,
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+					listvalidator.ConflictsWith(path.MatchRoot("token")),
+				},
+			},
+			"config_path": schema.StringAttribute{
+				MarkdownDescription: "Path to the kraftkit configuration file from which the API token is read " +
+					"when it is not otherwise set. Defaults to `~/.config/kraftkit/config.yaml`.",
+				Optional: true,
+			},
+			"profile": schema.StringAttribute{
+				MarkdownDescription: "Entry of the `auth` section of the kraftkit configuration file to read " +
+					"credentials from. Defaults to `" + defaultKraftkitProfile + "`.",
+				Optional: true,
+			},
+			"endpoint": schema.StringAttribute{
+				MarkdownDescription: "Base URL of the Unikraft Cloud API, e.g. `https://ukc.example.com`. Replaces the " +
+					"URL derived from the metro for all API requests. Intended for on-premises deployments and stand-in " +
+					"API servers.",
+				Optional: true,
+			},
+			"ca_cert_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM-encoded CA certificates trusted in addition to the system's certificate pool " +
+					"when verifying the certificate of the API.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(path.MatchRoot("ca_cert_file")),
+				},
+			},
+			"ca_cert_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a file containing PEM-encoded CA certificates trusted in addition to the " +
+					"system's certificate pool when verifying the certificate of the API.",
+				Optional: true,
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				MarkdownDescription: "Disable the verification of the API's TLS certificate. Do not use in production.",
+				Optional:            true,
+			},
+			"max_retries": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Maximum number of times an API request which failed with a transient "+
+					"error (server error, rate limiting, connection reset) is retried. Defaults to `%d`. Set to `0` to "+
+					"disable retries.", defaultMaxRetries),
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"retry_max_wait": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("Maximum time to wait between two attempts of an API request, such as "+
+					"`\"10s\"`. Defaults to `\"%s\"`. A `Retry-After` header sent by the API is honoured up to this "+
+					"duration.", defaultRetryMaxWait),
+				Optional: true,
+				Validators: []validator.String{
+					isDuration(),
+				},
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of API requests sent concurrently by the provider, across all " +
+					"resources, data sources and metros. Unlimited by default.",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"max_creates_per_apply": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of instances created in a single Terraform run, across all " +
+					"resources. Further creations fail once the limit is reached. Unlimited by default.",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"max_deletes_per_apply": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of instances deleted in a single Terraform run, across all " +
+					"resources, including instances replaced by new ones. Further deletions fail once the limit is " +
+					"reached. Unlimited by default.",
+				Optional: true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"read_only": schema.BoolAttribute{
+				MarkdownDescription: "Prevent any change to Unikraft Cloud resources. Operations which would create, " +
+					"modify or delete resources fail, while resources can still be read and data sources used. " +
+					"Intended for running `terraform plan` with production credentials.",
+				Optional: true,
+			},
+			"audit_log_path": schema.StringAttribute{
+				MarkdownDescription: "Path to a file to which a record of every API call which modifies Unikraft Cloud " +
+					"resources is appended, in the JSON Lines format. Environment variables are redacted, arguments are " +
+					"recorded as is.",
+				Optional: true,
+			},
+			"skip_credentials_validation": schema.BoolAttribute{
+				MarkdownDescription: "Skip the validation of the API token against the default metro when the " +
+					"provider is configured. By default, an invalid token causes the provider's configuration to " +
+					"fail, before any change is planned.",
+				Optional: true,
+			},
+			"emulator": schema.BoolAttribute{
+				MarkdownDescription: "Serve all API requests with an embedded emulator of Unikraft Cloud instead of " +
+					"the actual platform, e.g. for offline plans and demos. No API token is required. Can also be set " +
+					"using the `UKC_EMULATOR` environment variable.",
+				Optional: true,
+			},
+			"emulator_state_path": schema.StringAttribute{
+				MarkdownDescription: "Path to the file in which the state of the emulator is persisted between runs. " +
+					"Defaults to `" + defaultEmulatorStatePath + "` in the working directory. Provider configurations " +
+					"which use the same file share the same instances. A lock file with the `.lock` suffix is created " +
+					"next to it.",
+				Optional: true,
+			},
+			"tracing": schema.SingleNestedAttribute{
+				MarkdownDescription: "Export OpenTelemetry traces of the provider's operations and of its API " +
+					"requests. Spans are exported to every configured destination.",
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"otlp_endpoint": schema.StringAttribute{
+						MarkdownDescription: "URL of an OTLP/HTTP endpoint to which spans are exported, e.g. " +
+							"`http://localhost:4318/v1/traces`.",
+						Optional: true,
+					},
+					"otlp_insecure": schema.BoolAttribute{
+						MarkdownDescription: "Export spans to the OTLP endpoint without TLS, regardless of the " +
+							"scheme of its URL.",
+						Optional: true,
+					},
+					"file_path": schema.StringAttribute{
+						MarkdownDescription: "Path to a file to which spans are appended, one JSON object per line.",
+						Optional:            true,
+					},
+				},
+			},
+			"namespace": schema.StringAttribute{
+				MarkdownDescription: "Registry namespace of the account, such as `myuser.unikraft.io`, used to qualify " +
+					"short image references of instances, e.g. `myapp:latest` becomes `myuser.unikraft.io/myapp:latest`. " +
+					"Can also be set using the `UKC_NAMESPACE` environment variable. Defaults to the namespace of the " +
+					"owner of the API token.",
+				Optional: true,
+			},
+			"allowed_image_patterns": schema.ListAttribute{
+				ElementType: types.StringType,
+				MarkdownDescription: "Images which instances are allowed to be created from. Each pattern is either a " +
+					"glob, such as `myuser.unikraft.io/*`, or a registry and/or namespace prefix, such as " +
+					"`myuser.unikraft.io/`. Instances with an image which matches none of the patterns fail to plan. " +
+					"All images are allowed by default.",
+				Optional: true,
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(stringvalidator.LengthAtLeast(1)),
+				},
+			},
+			"defaults": schema.SingleNestedAttribute{
+				MarkdownDescription: "Default settings of instances, used by `unikraft-cloud_instance` resources " +
+					"which do not configure them explicitly. Defaults only apply to instances which are created after " +
+					"they are set.",
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"memory_mb": schema.Int64Attribute{
+						MarkdownDescription: "Default amount of memory of instances, in MiB.",
+						Optional:            true,
+						Validators: []validator.Int64{
+							int64validator.Between(16, 256),
+						},
+					},
+					"autostart": schema.BoolAttribute{
+						MarkdownDescription: "Whether instances are started upon creation by default.",
+						Optional:            true,
+					},
+					"env": schema.MapAttribute{
+						ElementType:         types.StringType,
+						MarkdownDescription: "Default environment variables of instances. Variables set by instances take precedence.",
+						Optional:            true,
+					},
+					"restart_policy": schema.StringAttribute{
+						MarkdownDescription: "Default restart policy of instances. One of `never`, `always`, `on-failure`.",
+						Optional:            true,
+						Validators: []validator.String{
+							stringvalidator.OneOf(restartPolicies...),
+						},
+					},
+				},
+			},
 		},
 	}
 }
 
 // Configure implements provider.Provider.
 func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	// Values which depend on resources which are not yet applied are unknown.
+	// When supported by Terraform, the planning of all resources and data
+	// sources is deferred to a later plan/apply round, once those values are
+	// known, instead of failing. This is checked before decoding the
+	// configuration, which fails on unknown nested objects.
+	if !req.Config.Raw.IsFullyKnown() && req.ClientCapabilities.DeferralAllowed {
+		tflog.Info(ctx, "Provider configuration contains unknown values, deferring all resources and data sources")
+		resp.Deferred = &provider.Deferred{
+			Reason: provider.DeferredReasonProviderConfigUnknown,
+		}
+		return
+	}
+
 	var data UnikraftCloudModel
 
 	// Retrieve provider data from configuration
@@ -79,6 +377,24 @@ func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.Conf
 		return
 	}
 
+	// Tracing is set up first, so that the configuration of the provider
+	// itself can be traced.
+	var tracer trace.Tracer
+	if data.Tracing != nil {
+		var err error
+		if tracer, err = newTracer(ctx, p.version, data.Tracing); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("tracing"),
+				"Invalid Tracing Configuration",
+				"The provider cannot export traces: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	ctx, span := startSpan(ctx, tracer, "provider.Configure")
+	defer func() { endSpan(span, resp.Diagnostics) }()
+
 	// If a configuration value was provided for any of the attributes, it must
 	// be a known value (either literal, or already resolved by Terraform).
 
@@ -100,6 +416,79 @@ func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.Conf
 		)
 	}
 
+	if data.TokenCommand.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("token_command"),
+			"Unknown Unikraft Cloud API Token Command",
+			"The provider cannot obtain the Unikraft Cloud API token as there is an unknown configuration value for the token command. "+
+				"Either target apply the source of the value first, or set the value statically in the configuration.",
+		)
+	}
+
+	if data.ConfigPath.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("config_path"),
+			"Unknown kraftkit Configuration Path",
+			"The provider cannot read the kraftkit configuration file as there is an unknown configuration value for its path. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the UKC_CONFIG_PATH environment variable.",
+		)
+	}
+
+	if data.Profile.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("profile"),
+			"Unknown kraftkit Configuration Profile",
+			"The provider cannot read the kraftkit configuration file as there is an unknown configuration value for the profile. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the UKC_PROFILE environment variable.",
+		)
+	}
+
+	if data.Endpoint.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("endpoint"),
+			"Unknown Unikraft Cloud API Endpoint",
+			"The provider cannot create the Unikraft Cloud API client as there is an unknown configuration value for the Unikraft Cloud API endpoint. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the UKC_ENDPOINT environment variable.",
+		)
+	}
+
+	if data.CACertPEM.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("ca_cert_pem"),
+			"Unknown CA Certificate",
+			"The provider cannot create the Unikraft Cloud API client as there is an unknown configuration value for the CA certificate. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the ca_cert_file attribute.",
+		)
+	}
+
+	if data.CACertFile.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("ca_cert_file"),
+			"Unknown CA Certificate File",
+			"The provider cannot create the Unikraft Cloud API client as there is an unknown configuration value for the CA certificate file. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the UKC_CA_CERT_FILE environment variable.",
+		)
+	}
+
+	if data.InsecureSkipVerify.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("insecure_skip_verify"),
+			"Unknown TLS Verification Setting",
+			"The provider cannot create the Unikraft Cloud API client as there is an unknown configuration value for insecure_skip_verify. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the UKC_INSECURE_SKIP_VERIFY environment variable.",
+		)
+	}
+
+	// Ignoring unknown patterns, or unknown elements, would allow any image.
+	if v, _ := data.AllowedImagePatterns.ToTerraformValue(ctx); !v.IsFullyKnown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("allowed_image_patterns"),
+			"Unknown Allowed Image Patterns",
+			"The provider cannot restrict the images of instances as there is an unknown configuration value for the allowed image patterns. "+
+				"Either target apply the source of the value first, or set the value statically in the configuration.",
+		)
+	}
+
 	if resp.Diagnostics.HasError() {
 		return
 	}
@@ -108,9 +497,6 @@ func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.Conf
 	// configuration values when provided.
 
 	metro := os.Getenv("UKC_METRO")
-	if metro == "" {
-		metro = client.DefaultMetro
-	}
 	if !data.Metro.IsNull() {
 		metro = data.Metro.ValueString()
 	}
@@ -120,6 +506,88 @@ func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.Conf
 		token = data.Token.ValueString()
 	}
 
+	if !data.TokenCommand.IsNull() {
+		var argv []string
+		resp.Diagnostics.Append(data.TokenCommand.ElementsAs(ctx, &argv, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		var err error
+		if token, err = p.tokens.Token(ctx, argv); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("token_command"),
+				"Failed to Obtain Unikraft Cloud API Token",
+				"The provider cannot obtain the Unikraft Cloud API token from the configured token command: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	emulated := false
+	if v := os.Getenv("UKC_EMULATOR"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid UKC_EMULATOR Environment Variable",
+				fmt.Sprintf("Expected a boolean value, got: %q", v),
+			)
+			return
+		}
+		emulated = b
+	}
+	if !data.Emulator.IsNull() && !data.Emulator.IsUnknown() {
+		emulated = data.Emulator.ValueBool()
+	}
+	emulated = emulated || p.emulated
+
+	// The emulator accepts any token.
+	if emulated && token == "" {
+		token = "emulator"
+	}
+
+	// Fall back to the credentials of the kraft CLI if the token is set
+	// neither in the configuration nor in the environment.
+
+	if token == "" {
+		cfgPath := os.Getenv("UKC_CONFIG_PATH")
+		if !data.ConfigPath.IsNull() {
+			cfgPath = data.ConfigPath.ValueString()
+		}
+
+		profile := os.Getenv("UKC_PROFILE")
+		if !data.Profile.IsNull() {
+			profile = data.Profile.ValueString()
+		}
+
+		// A missing configuration file is only an error if the user
+		// explicitly asked for it to be read.
+		explicit := cfgPath != "" || profile != ""
+
+		if cfgPath == "" {
+			cfgPath = defaultKraftkitConfigPath()
+		}
+		if profile == "" {
+			profile = defaultKraftkitProfile
+		}
+
+		auth, err := readKraftkitAuth(cfgPath, profile, explicit)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("config_path"),
+				"Invalid kraftkit Configuration",
+				"The provider cannot read the Unikraft Cloud API credentials from the kraftkit configuration file: "+err.Error(),
+			)
+			return
+		}
+
+		token = auth.apiToken()
+	}
+
+	if metro == "" {
+		metro = client.DefaultMetro
+	}
+
 	// If any of the expected configurations are still missing at this point,
 	// fail the provider's configuration phase.
 
@@ -137,22 +605,301 @@ func (p *UnikraftCloudProvider) Configure(ctx context.Context, req provider.Conf
 			path.Root("token"),
 			"Missing Unikraft Cloud API Token",
 			"The provider cannot create the Unikraft Cloud API client as there is a missing or empty configuration value for the Unikraft Cloud API token. "+
-				"Set the token value in the configuration or use the UKC_TOKEN environment variable.",
+				"The token is looked up in the following sources, in order of precedence: "+
+				"the token or token_command value in the configuration, "+
+				"the UKC_TOKEN environment variable, "+
+				"the kraftkit configuration file written by \"kraft login\" (see config_path and profile).",
 		)
 	}
 
+	// Consider values from environment variables for the settings of the HTTP
+	// client, but override with explicit configuration values when provided.
+
+	httpCfg := httpClientConfig{
+		MaxRetries:   defaultMaxRetries,
+		RetryMaxWait: defaultRetryMaxWait,
+		Secrets:      []string{token},
+		Tracer:       tracer,
+	}
+
+	httpCfg.Endpoint = os.Getenv("UKC_ENDPOINT")
+	if !data.Endpoint.IsNull() {
+		httpCfg.Endpoint = data.Endpoint.ValueString()
+	}
+	if httpCfg.Endpoint != "" {
+		if _, err := parseEndpoint(httpCfg.Endpoint); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("endpoint"),
+				"Invalid Unikraft Cloud API Endpoint",
+				"The provider cannot create the Unikraft Cloud API client as the configured API endpoint is invalid: "+err.Error(),
+			)
+		}
+	}
+
+	// Metros are meaningless when all requests are sent to a custom endpoint.
+	var metros *metroCatalog
+	if httpCfg.Endpoint == "" {
+		var customMetros []string
+		if !data.CustomMetros.IsNull() && !data.CustomMetros.IsUnknown() {
+			resp.Diagnostics.Append(data.CustomMetros.ElementsAs(ctx, &customMetros, false)...)
+		}
+		metros = newMetroCatalog(customMetros...)
+	}
+
+	if err := metros.Validate(metro); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("metro"),
+			"Invalid Unikraft Cloud API Metro",
+			"The provider cannot create the Unikraft Cloud API client as the configured metro is invalid: "+err.Error(),
+		)
+	}
+
+	caCertFile := os.Getenv("UKC_CA_CERT_FILE")
+	if !data.CACertFile.IsNull() {
+		caCertFile = data.CACertFile.ValueString()
+	}
+
+	switch {
+	case !data.CACertPEM.IsNull():
+		httpCfg.CACertPEM = []byte(data.CACertPEM.ValueString())
+	case caCertFile != "":
+		b, err := os.ReadFile(caCertFile)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ca_cert_file"),
+				"Invalid CA Certificate File",
+				"The provider cannot read the CA certificate file: "+err.Error(),
+			)
+		}
+		httpCfg.CACertPEM = b
+	}
+
+	if v := os.Getenv("UKC_INSECURE_SKIP_VERIFY"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid UKC_INSECURE_SKIP_VERIFY Environment Variable",
+				fmt.Sprintf("Expected a boolean value, got: %q", v),
+			)
+		}
+		httpCfg.InsecureSkipVerify = b
+	}
+	if !data.InsecureSkipVerify.IsNull() {
+		httpCfg.InsecureSkipVerify = data.InsecureSkipVerify.ValueBool()
+	}
+
+	if !data.MaxRetries.IsNull() && !data.MaxRetries.IsUnknown() {
+		httpCfg.MaxRetries = int(data.MaxRetries.ValueInt64())
+	}
+	if !data.RetryMaxWait.IsNull() && !data.RetryMaxWait.IsUnknown() {
+		// The format of the value was already checked by the attribute's
+		// validator.
+		httpCfg.RetryMaxWait, _ = time.ParseDuration(data.RetryMaxWait.ValueString())
+	}
+
+	if !data.MaxConcurrentRequests.IsNull() && !data.MaxConcurrentRequests.IsUnknown() {
+		httpCfg.MaxConcurrentRequests = int(data.MaxConcurrentRequests.ValueInt64())
+	}
+
+	if v := os.Getenv("UKC_READ_ONLY"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid UKC_READ_ONLY Environment Variable",
+				fmt.Sprintf("Expected a boolean value, got: %q", v),
+			)
+		}
+		httpCfg.ReadOnly = b
+	}
+	if !data.ReadOnly.IsNull() && !data.ReadOnly.IsUnknown() {
+		httpCfg.ReadOnly = data.ReadOnly.ValueBool()
+	}
+
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	// Client configuration for data sources and resources
-	client := unikraftcloud.NewClient(
-		unikraftcloud.WithDefaultMetro(metro),
-		unikraftcloud.WithToken(token),
+	if emulated {
+		statePath := defaultEmulatorStatePath
+		if !data.EmulatorStatePath.IsNull() && !data.EmulatorStatePath.IsUnknown() {
+			statePath = data.EmulatorStatePath.ValueString()
+		}
+
+		emu, err := p.emulator(statePath)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("emulator_state_path"),
+				"Unable to Start Emulator",
+				"The provider cannot restore the state of the emulator: "+err.Error(),
+			)
+			return
+		}
+		httpCfg.Emulator = emu
+
+		tflog.Info(ctx, "Serving all Unikraft Cloud API requests with the embedded emulator", map[string]any{
+			"emulator_state_path": statePath,
+		})
+	}
+
+	httpClient, err := newHTTPClient(httpCfg)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Create Unikraft Cloud API Client",
+			"The provider cannot create the HTTP client of the Unikraft Cloud API: "+err.Error(),
+		)
+		return
+	}
+
+	var audit *auditLogger
+	if !data.AuditLogPath.IsNull() && !data.AuditLogPath.IsUnknown() {
+		if audit, err = newAuditLogger(data.AuditLogPath.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("audit_log_path"),
+				"Invalid Audit Log Path",
+				"The provider cannot write to the audit log: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	namespace := os.Getenv("UKC_NAMESPACE")
+	if !data.Namespace.IsNull() && !data.Namespace.IsUnknown() {
+		namespace = data.Namespace.ValueString()
+	}
+	if namespace == "" {
+		namespace = namespaceFromToken(token)
+	}
+
+	var images *imagePolicy
+	if !data.AllowedImagePatterns.IsNull() {
+		var patterns []string
+		resp.Diagnostics.Append(data.AllowedImagePatterns.ElementsAs(ctx, &patterns, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if images, err = newImagePolicy(patterns...); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("allowed_image_patterns"),
+				"Invalid Image Pattern",
+				"The provider cannot enforce the allowed image patterns: "+err.Error(),
+			)
+			return
+		}
+	}
+
+	// Limits apply to the whole provider process, even if the provider is
+	// configured multiple times.
+	p.creates.SetMax(int(data.MaxCreatesPerApply.ValueInt64()))
+	p.deletes.SetMax(int(data.MaxDeletesPerApply.ValueInt64()))
+
+	// Client configuration for data sources and resources. Clients are built
+	// lazily for each metro referenced by a resource or data source.
+	pdata := &providerData{
+		clients: newClientPool(metro, metros,
+			unikraftcloud.WithToken(token),
+			unikraftcloud.WithHTTPClient(httpClient),
+		),
+		defaults:  data.Defaults,
+		readOnly:  httpCfg.ReadOnly,
+		audit:     audit,
+		tracer:    tracer,
+		images:    images,
+		namespace: namespace,
+		creates:   &p.creates,
+		deletes:   &p.deletes,
+	}
+
+	skipCredsValidation := false
+	if v := os.Getenv("UKC_SKIP_CREDENTIALS_VALIDATION"); v != "" {
+		if skipCredsValidation, err = strconv.ParseBool(v); err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid UKC_SKIP_CREDENTIALS_VALIDATION Environment Variable",
+				fmt.Sprintf("Expected a boolean value, got: %q", v),
+			)
+			return
+		}
+	}
+	if !data.SkipCredentialsValidation.IsNull() && !data.SkipCredentialsValidation.IsUnknown() {
+		skipCredsValidation = data.SkipCredentialsValidation.ValueBool()
+	}
+
+	if !skipCredsValidation {
+		resp.Diagnostics.Append(validateCredentials(ctx, pdata.clients, metro)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	resp.DataSourceData = pdata
+	resp.ResourceData = pdata
+}
+
+// emulator returns the emulator which persists its state to the file at the
+// given path, creating it on first use.
+func (p *UnikraftCloudProvider) emulator(statePath string) (*emulator, error) {
+	p.emulatorsMu.Lock()
+	defer p.emulatorsMu.Unlock()
+
+	if e, ok := p.emulators[statePath]; ok {
+		return e, nil
+	}
+
+	e, err := newEmulator(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.emulators == nil {
+		p.emulators = make(map[string]*emulator)
+	}
+	p.emulators[statePath] = e
+
+	return e, nil
+}
+
+// validateCredentials ensures that the API token is accepted by the API of the
+// given metro, by performing a cheap authenticated request.
+func validateCredentials(ctx context.Context, clients *clientPool, metro string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	ctx = withAPIErrorRecorder(ctx)
+
+	_, err := clients.Users(metro).Quotas(ctx)
+	if err == nil {
+		return diags
+	}
+
+	apiErr := recordedAPIError(ctx)
+
+	// The token was accepted, but is not allowed to read the quotas of the
+	// account, which are only used to validate it.
+	if apiErr != nil && apiErr.StatusCode == http.StatusForbidden {
+		tflog.Info(ctx, "Skipping the validation of the Unikraft Cloud API token, which is not allowed to read quotas", map[string]any{
+			logFieldMetro: metro,
+			"error":       apiErr.Error(),
+		})
+		return diags
+	}
+
+	if apiErr != nil && apiErr.StatusCode == http.StatusUnauthorized {
+		diags.AddAttributeError(
+			path.Root("token"),
+			"Invalid Unikraft Cloud API Token",
+			fmt.Sprintf("The Unikraft Cloud API token was rejected for metro %q. "+
+				"Ensure that the token set in the provider configuration, in the UKC_TOKEN environment variable "+
+				"or in the kraftkit configuration file is valid and has not expired.\n\nAPI error: %s", metro, apiErr),
+		)
+		return diags
+	}
+
+	diags.AddError(
+		"Unable to Validate Unikraft Cloud API Credentials",
+		fmt.Sprintf("The provider cannot validate the Unikraft Cloud API token for metro %q, got error: %v\n\n"+
+			"Set skip_credentials_validation to true in the provider configuration to skip this validation.", metro, err),
 	)
 
-	resp.DataSourceData = client.Instances()
-	resp.ResourceData = client.Instances()
+	return diags
 }
 
 // Resources describes the provider data model.
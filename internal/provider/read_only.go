@@ -0,0 +1,46 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// readOnlyDiagnostic returns the diagnostic reported when an operation which
+// would modify Unikraft Cloud resources is attempted while the provider is in
+// read-only mode.
+func readOnlyDiagnostic(op string) diag.Diagnostic {
+	return diag.NewErrorDiagnostic(
+		"Provider in Read-Only Mode",
+		fmt.Sprintf("Refusing to %s because the provider is configured in read-only mode (read_only = true). "+
+			"In this mode, only reading resources and data sources is allowed. "+
+			"Unset read_only in the provider configuration to apply changes.", op),
+	)
+}
+
+// readOnlyTransport is a http.RoundTripper which rejects all API requests
+// that may modify Unikraft Cloud resources. It acts as a safety net for
+// operations which are not explicitly guarded by resources.
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+var _ http.RoundTripper = (*readOnlyTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	return nil, fmt.Errorf("refusing to send %s request to %s: the provider is in read-only mode", req.Method, req.URL.Redacted())
+}
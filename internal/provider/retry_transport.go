@@ -0,0 +1,189 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	// defaultMaxRetries is the default number of times a failed API request is
+	// retried.
+	defaultMaxRetries = 3
+	// defaultRetryMaxWait is the default upper bound of the time waited
+	// between two attempts of the same API request.
+	defaultRetryMaxWait = 30 * time.Second
+
+	// retryMinWait is the time waited before the first retry of an API
+	// request, doubled with every subsequent attempt.
+	retryMinWait = 1 * time.Second
+	// retryMaxShift is the number of attempts after which the wait is no
+	// longer doubled, so that shifting retryMinWait can not overflow.
+	retryMaxShift = 30
+)
+
+// retryTransport is a http.RoundTripper which retries requests that failed
+// because of a transient error, with an exponential backoff.
+//
+// Requests with idempotent methods are retried on server errors, rate
+// limiting and connection resets. Other requests are only retried when they
+// were rejected by rate limiting, since they may have already been processed
+// otherwise.
+type retryTransport struct {
+	maxRetries int
+	maxWait    time.Duration
+	next       http.RoundTripper
+}
+
+var _ http.RoundTripper = (*retryTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+
+		if attempt >= t.maxRetries || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+
+		fields := map[string]any{
+			"method":  req.Method,
+			"url":     req.URL.Redacted(),
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status_code"] = resp.StatusCode
+		}
+		tflog.Info(ctx, "Retrying Unikraft Cloud API request", fields)
+
+		if resp != nil {
+			// Drain the body so that the underlying connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry returns whether the given request should be attempted again
+// based on the outcome of its last attempt.
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	// The body of the request can not be replayed.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false
+		}
+		return isIdempotent(req.Method) && isConnReset(err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusNotImplemented:
+		return false
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return isIdempotent(req.Method)
+	}
+
+	return false
+}
+
+// backoff returns the time to wait before the next attempt of a request. The
+// server's Retry-After header is honoured when present.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(wait, t.maxWait)
+		}
+	}
+
+	if attempt > retryMaxShift {
+		return t.maxWait
+	}
+	wait := retryMinWait << attempt
+	if wait <= 0 || wait >= t.maxWait {
+		return t.maxWait
+	}
+	// Add up to 25% of jitter to avoid synchronized retries from concurrent
+	// resource operations.
+	wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+
+	return min(wait, t.maxWait)
+}
+
+// parseRetryAfter parses the value of a Retry-After HTTP header, which is
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+
+	return 0, false
+}
+
+// isIdempotent returns whether requests with the given method can safely be
+// sent multiple times.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isConnReset returns whether err indicates that the connection to the server
+// was unexpectedly interrupted.
+func isConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
@@ -0,0 +1,291 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	testCases := map[string]struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		"empty": {
+			value: "",
+		},
+		"seconds": {
+			value:  "12",
+			want:   12 * time.Second,
+			wantOK: true,
+		},
+		"zero": {
+			value:  "0",
+			wantOK: true,
+		},
+		"negative": {
+			value: "-1",
+		},
+		"date in the past": {
+			value:  "Wed, 21 Oct 2015 07:28:00 GMT",
+			wantOK: true,
+		},
+		"invalid": {
+			value: "soon",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tc.value)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("parseRetryAfter(%q) = %v, %t; expected %v, %t", tc.value, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+
+	t.Run("date in the future", func(t *testing.T) {
+		v := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+		got, ok := parseRetryAfter(v)
+		if !ok || got <= 0 || got > time.Minute {
+			t.Errorf("parseRetryAfter(%q) = %v, %t; expected up to a minute", v, got, ok)
+		}
+	})
+}
+
+func TestRetryTransportShouldRetry(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := map[string]struct {
+		method string
+		// body is a body which can not be replayed.
+		body   io.Reader
+		ctx    context.Context
+		status int
+		err    error
+		want   bool
+	}{
+		"GET server error": {
+			method: http.MethodGet,
+			status: http.StatusBadGateway,
+			want:   true,
+		},
+		"POST server error": {
+			method: http.MethodPost,
+			status: http.StatusBadGateway,
+		},
+		"GET rate limited": {
+			method: http.MethodGet,
+			status: http.StatusTooManyRequests,
+			want:   true,
+		},
+		"POST rate limited": {
+			method: http.MethodPost,
+			status: http.StatusTooManyRequests,
+			want:   true,
+		},
+		"POST rate limited without replayable body": {
+			method: http.MethodPost,
+			body:   io.MultiReader(strings.NewReader("{}")),
+			status: http.StatusTooManyRequests,
+		},
+		"not implemented": {
+			method: http.MethodGet,
+			status: http.StatusNotImplemented,
+		},
+		"client error": {
+			method: http.MethodGet,
+			status: http.StatusNotFound,
+		},
+		"success": {
+			method: http.MethodGet,
+			status: http.StatusOK,
+		},
+		"GET connection reset": {
+			method: http.MethodGet,
+			err:    syscall.ECONNRESET,
+			want:   true,
+		},
+		"POST connection reset": {
+			method: http.MethodPost,
+			err:    syscall.ECONNRESET,
+		},
+		"DELETE unexpected EOF": {
+			method: http.MethodDelete,
+			err:    io.ErrUnexpectedEOF,
+			want:   true,
+		},
+		"other error": {
+			method: http.MethodGet,
+			err:    errors.New("no such host"),
+		},
+		"canceled request": {
+			method: http.MethodGet,
+			ctx:    canceled,
+			err:    syscall.ECONNRESET,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			req, err := http.NewRequestWithContext(ctx, tc.method, "https://api.example.com/v1/instances", tc.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var resp *http.Response
+			if tc.err == nil {
+				resp = &http.Response{StatusCode: tc.status}
+			}
+
+			tr := &retryTransport{}
+			if got := tr.shouldRetry(req, resp, tc.err); got != tc.want {
+				t.Errorf("shouldRetry() = %t; expected %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	testCases := map[string]struct {
+		attempt    int
+		retryAfter string
+		maxWait    time.Duration
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		"first attempt": {
+			attempt: 0,
+			maxWait: time.Minute,
+			wantMin: retryMinWait,
+			wantMax: retryMinWait * 5 / 4,
+		},
+		"third attempt": {
+			attempt: 2,
+			maxWait: time.Minute,
+			wantMin: 4 * retryMinWait,
+			wantMax: 5 * retryMinWait,
+		},
+		"capped by the maximum wait": {
+			attempt: 10,
+			maxWait: 30 * time.Second,
+			wantMin: 30 * time.Second,
+			wantMax: 30 * time.Second,
+		},
+		"large attempt": {
+			attempt: 50,
+			maxWait: 30 * time.Second,
+			wantMin: 30 * time.Second,
+			wantMax: 30 * time.Second,
+		},
+		"overflowing attempt": {
+			attempt: 34,
+			maxWait: 30 * time.Second,
+			wantMin: 30 * time.Second,
+			wantMax: 30 * time.Second,
+		},
+		"retry after": {
+			attempt:    5,
+			retryAfter: "2",
+			maxWait:    30 * time.Second,
+			wantMin:    2 * time.Second,
+			wantMax:    2 * time.Second,
+		},
+		"retry after capped by the maximum wait": {
+			attempt:    0,
+			retryAfter: "120",
+			maxWait:    30 * time.Second,
+			wantMin:    30 * time.Second,
+			wantMax:    30 * time.Second,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var resp *http.Response
+			if tc.retryAfter != "" {
+				resp = &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": []string{tc.retryAfter}},
+				}
+			}
+
+			tr := &retryTransport{maxWait: tc.maxWait}
+			if got := tr.backoff(tc.attempt, resp); got < tc.wantMin || got > tc.wantMax {
+				t.Errorf("backoff(%d) = %v; expected between %v and %v", tc.attempt, got, tc.wantMin, tc.wantMax)
+			}
+		})
+	}
+}
+
+// roundTripFunc is a http.RoundTripper implemented by a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransportBodyReplay(t *testing.T) {
+	const body = `{"image":"nginx:latest"}`
+
+	var bodies []string
+	tr := &retryTransport{
+		maxRetries: 2,
+		maxWait:    time.Millisecond,
+		next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, string(b))
+
+			status := http.StatusTooManyRequests
+			if len(bodies) == 3 {
+				status = http.StatusCreated
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Retry-After": []string{"0"}},
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com/v1/instances", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(bodies))
+	}
+	for i, b := range bodies {
+		if b != body {
+			t.Errorf("attempt %d: expected body %q, got %q", i+1, body, b)
+		}
+	}
+}
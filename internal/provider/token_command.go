@@ -0,0 +1,76 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// tokenCommandCache caches the API tokens obtained by executing token
+// commands, so that each command is executed at most once during the lifetime
+// of the provider process.
+type tokenCommandCache struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// Token returns the API token written by the given command to its standard
+// output, executing the command if its result is not already cached.
+func (c *tokenCommandCache) Token(ctx context.Context, argv []string) (string, error) {
+	key := strings.Join(argv, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tok, ok := c.tokens[key]; ok {
+		return tok, nil
+	}
+
+	tok, err := runTokenCommand(ctx, argv)
+	if err != nil {
+		return "", err
+	}
+
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+	c.tokens[key] = tok
+
+	return tok, nil
+}
+
+// runTokenCommand executes the given command and returns the API token written
+// to its standard output. The standard error of the command is included in the
+// returned error on failure.
+func runTokenCommand(ctx context.Context, argv []string) (string, error) {
+	if len(argv) == 0 || argv[0] == "" {
+		return "", errors.New("no command specified")
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("running %q: %w\n\nstderr:\n%s", argv[0], err, msg)
+		}
+		return "", fmt.Errorf("running %q: %w", argv[0], err)
+	}
+
+	tok := strings.TrimSpace(stdout.String())
+	if tok == "" {
+		return "", fmt.Errorf("command %q did not write a token to its standard output", argv[0])
+	}
+
+	return tok, nil
+}
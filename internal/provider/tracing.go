@@ -0,0 +1,207 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the OpenTelemetry tracer of the provider.
+const tracerName = "github.com/unikraft-cloud/terraform-provider-unikraft-cloud"
+
+// Keys of span attributes which identify the Terraform object and the
+// Unikraft Cloud object concerned by an operation.
+const (
+	spanAttrResourceType   = attribute.Key("terraform.resource_type")
+	spanAttrDataSourceType = attribute.Key("terraform.data_source_type")
+	spanAttrMetro          = attribute.Key("unikraft_cloud.metro")
+	spanAttrInstanceUUID   = attribute.Key("unikraft_cloud.instance.uuid")
+)
+
+// tracingExportTimeout bounds the duration of each export of spans, so that an
+// unreachable collector does not hold the provider up.
+const tracingExportTimeout = 5 * time.Second
+
+// tracerProviders holds the tracer providers created by the provider, so that
+// their buffered spans can be flushed by ShutdownTracing, and the trace files
+// they export spans to, so that those can be closed afterwards.
+var tracerProviders struct {
+	mu    sync.Mutex
+	tps   []*sdktrace.TracerProvider
+	files []*os.File
+}
+
+// tracingModel describes the data model of the provider's tracing settings.
+type tracingModel struct {
+	OTLPEndpoint types.String `tfsdk:"otlp_endpoint"`
+	OTLPInsecure types.Bool   `tfsdk:"otlp_insecure"`
+	FilePath     types.String `tfsdk:"file_path"`
+}
+
+// newTracer returns an OpenTelemetry tracer which exports spans to the
+// destinations configured in cfg.
+//
+// Spans are exported in batches in the background, so that exports do not
+// delay the operations of the provider. ShutdownTracing must be called before
+// the provider's process exits to export the remaining spans.
+func newTracer(ctx context.Context, version string, cfg *tracingModel) (trace.Tracer, error) {
+	res, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("terraform-provider-unikraft-cloud"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("building tracing resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+	}
+
+	if v := cfg.OTLPEndpoint.ValueString(); v != "" {
+		otlpOpts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpointURL(v),
+			otlptracehttp.WithTimeout(tracingExportTimeout),
+		}
+		if cfg.OTLPInsecure.ValueBool() {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithInsecure())
+		}
+
+		exp, err := otlptracehttp.New(ctx, otlpOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp, sdktrace.WithExportTimeout(tracingExportTimeout)))
+	}
+
+	var file *os.File
+	if v := cfg.FilePath.ValueString(); v != "" {
+		f, err := os.OpenFile(v, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("opening trace file: %w", err)
+		}
+
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("creating file exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp, sdktrace.WithExportTimeout(tracingExportTimeout)))
+		file = f
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	tracerProviders.mu.Lock()
+	tracerProviders.tps = append(tracerProviders.tps, tp)
+	if file != nil {
+		tracerProviders.files = append(tracerProviders.files, file)
+	}
+	tracerProviders.mu.Unlock()
+
+	return tp.Tracer(tracerName), nil
+}
+
+// ShutdownTracing exports the spans buffered by all tracers of the provider,
+// then stops them and closes their trace files. It returns when all spans are
+// exported, or when ctx is done.
+func ShutdownTracing(ctx context.Context) error {
+	tracerProviders.mu.Lock()
+	tps, files := tracerProviders.tps, tracerProviders.files
+	tracerProviders.tps, tracerProviders.files = nil, nil
+	tracerProviders.mu.Unlock()
+
+	var errs []error
+	for _, tp := range tps {
+		if err := tp.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Trace files are written to by the exporters until their tracer provider
+	// is shut down.
+	for _, f := range files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing trace file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startSpan starts a span for the given provider operation. If t is nil,
+// because tracing is disabled or the provider is not configured, the returned
+// span is a no-op.
+func startSpan(ctx context.Context, t trace.Tracer, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if t == nil {
+		t = noop.NewTracerProvider().Tracer(tracerName)
+	}
+	return t.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the given span, after recording the errors of the operation
+// it represents, if any.
+func endSpan(span trace.Span, diags diag.Diagnostics) {
+	if diags.HasError() {
+		for _, d := range diags.Errors() {
+			span.AddEvent("error", trace.WithAttributes(
+				attribute.String("summary", d.Summary()),
+				attribute.String("detail", d.Detail()),
+			))
+		}
+		span.SetStatus(codes.Error, diags.Errors()[0].Summary())
+	}
+	span.End()
+}
+
+// tracingTransport is a http.RoundTripper which records a span for every API
+// request, including all of its attempts.
+type tracingTransport struct {
+	tracer trace.Tracer
+	next   http.RoundTripper
+}
+
+var _ http.RoundTripper = (*tracingTransport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	return resp, nil
+}
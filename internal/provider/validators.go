@@ -0,0 +1,50 @@
+// Copyright (c) Unikraft GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+// durationValidator validates that a string attribute is a positive duration
+// in the format accepted by time.ParseDuration.
+type durationValidator struct{}
+
+var _ validator.String = durationValidator{}
+
+// isDuration returns a validator which ensures that a string attribute is a
+// positive duration, such as "30s" or "2m".
+func isDuration() validator.String {
+	return durationValidator{}
+}
+
+// Description implements validator.Describer.
+func (v durationValidator) Description(_ context.Context) string {
+	return "value must be a positive duration, such as \"30s\" or \"2m\""
+}
+
+// MarkdownDescription implements validator.Describer.
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+// ValidateString implements validator.String.
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	d, err := time.ParseDuration(req.ConfigValue.ValueString())
+	if err != nil || d <= 0 {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid Duration",
+			fmt.Sprintf("Attribute %s %s, got: %q", req.Path, v.Description(ctx), req.ConfigValue.ValueString()),
+		)
+	}
+}
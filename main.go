@@ -7,6 +7,7 @@ import (
 	"context"
 	"flag"
 	"log"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 
@@ -29,9 +30,10 @@ import (
 var version string = "dev"
 
 func main() {
-	var debug bool
+	var debug, emulated bool
 
 	flag.BoolVar(&debug, "debug", false, "run the provider with support for debuggers")
+	flag.BoolVar(&emulated, "emulator", false, "serve all API requests with an embedded emulator of Unikraft Cloud")
 	flag.Parse()
 
 	opts := providerserver.ServeOpts{
@@ -39,7 +41,21 @@ func main() {
 		Debug:   debug,
 	}
 
-	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+	newProvider := provider.New(version)
+	if emulated {
+		newProvider = provider.NewEmulated(version)
+	}
+
+	err := providerserver.Serve(context.Background(), newProvider, opts)
+
+	// Terraform kills the provider shortly after asking it to stop, so the
+	// export of buffered spans is bounded.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if terr := provider.ShutdownTracing(ctx); terr != nil {
+		log.Printf("failed to export traces: %v", terr)
+	}
+	cancel()
+
 	if err != nil {
 		log.Fatal(err)
 	}